@@ -0,0 +1,78 @@
+package main
+
+import (
+	"btwarch/config"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// loadConfig loads the configuration and checks that the server can run
+// with it.
+func loadConfig(path string) (*config.Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// configCommand runs `btwarch config check [file]`, which prints the
+// effective configuration with secrets masked, every problem and warning
+// found in it, and exits non-zero when the server would refuse to start.
+func configCommand(args []string) int {
+	if len(args) < 1 || args[0] != "check" || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: btwarch config check [file]")
+		return 2
+	}
+
+	path := os.Getenv("CONFIG_FILE")
+	if len(args) == 2 {
+		path = args[1]
+	}
+
+	cfg, err := config.Load(path)
+	if err == nil {
+		printSettings(os.Stdout, path, cfg.Settings())
+		for _, warning := range cfg.Warnings() {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+		}
+		err = cfg.Validate()
+	}
+
+	var configErr *config.Error
+	switch {
+	case errors.As(err, &configErr):
+		fmt.Fprintf(os.Stderr, "%d problem(s) found:\n", len(configErr.Problems))
+		for _, problem := range configErr.Problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+		}
+		return 1
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Println("Configuration OK")
+	return 0
+}
+
+func printSettings(w io.Writer, path string, settings []config.Setting) {
+	if path == "" {
+		fmt.Fprintln(w, "Configuration from the environment and defaults:")
+	} else {
+		fmt.Fprintf(w, "Configuration from %s, the environment and defaults:\n", path)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, setting := range settings {
+		fmt.Fprintf(tw, "  %s\t%s\t(%s)\n", setting.Key, setting.Value, setting.Source)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
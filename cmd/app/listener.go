@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation (SD_LISTEN_FDS_START).
+const listenFDsStart = 3
+
+// listen returns the socket inherited through systemd socket activation when
+// there is one, and otherwise listens on addr. An inherited socket stays open
+// while the service restarts, so clients wait instead of being refused.
+func listen(addr string) (net.Listener, bool, error) {
+	ln, err := activatedListener()
+	if err != nil {
+		return nil, false, err
+	}
+	if ln != nil {
+		return ln, true, nil
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	return ln, false, err
+}
+
+// activatedListener implements the LISTEN_PID/LISTEN_FDS protocol of
+// sd_listen_fds(3). It returns nil when no socket was passed to this process.
+func activatedListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+	if fds > 1 {
+		return nil, fmt.Errorf("expected one activated socket, got %d", fds)
+	}
+
+	// The variables are meant for this process only.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(listenFDsStart, "systemd-socket")
+	defer file.Close()
+
+	ln, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("error using activated socket: %w", err)
+	}
+	return ln, nil
+}
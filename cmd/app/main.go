@@ -1,50 +1,194 @@
 package main
 
 import (
-	"btwarch/config"
 	"btwarch/database"
+	"btwarch/events"
+	"btwarch/lifecycle"
+	"btwarch/logging"
+	"btwarch/metrics"
 	"btwarch/middleware"
+	"btwarch/notifications"
+	"btwarch/problem"
+	"btwarch/repositories"
 	"btwarch/routes"
-	"log"
+	"btwarch/services"
+	"btwarch/stream"
+	"btwarch/tracing"
+	"btwarch/webhooks"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	log.Println("Starting BTWArch API")
+	envErr := godotenv.Load()
 
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Error loading .env file: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
 	}
 
-	cfg := config.LoadConfig()
+	configFile := os.Getenv("CONFIG_FILE")
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logging.Setup(cfg)
+
+	slog.Info("Starting BTWArch API", "config_file", configFile)
+	if envErr != nil {
+		slog.Warn("Error loading .env file", "error", envErr)
+	}
+	for _, warning := range cfg.Warnings() {
+		slog.Warn("Configuration warning", "warning", warning)
+	}
 
-	if err := database.Connect(cfg.DatabaseURL); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "error", err)
+	}
+
+	if err := database.Connect(cfg.DatabaseURL, cfg.DatabaseStatementTimeout); err != nil {
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
-	defer database.Close()
 
 	if err := database.InitTables(); err != nil {
-		log.Fatalf("Failed to initialize database tables: %v", err)
+		logging.Fatal("Failed to initialize database tables", "error", err)
+	}
+
+	metrics.RegisterDBStats(database.DB)
+	metrics.RegisterBusinessStats(repositories.NewStatsRepository().GetBusinessStats)
+
+	notifier := notifications.NewNotifier(cfg)
+	userRepo := repositories.NewUserRepository()
+	claimRepo := repositories.NewSubdomainClaimRepository()
+	recordRepo := repositories.NewRecordRepository(cfg)
+	waitlistRepo := repositories.NewWaitlistRepository()
+	webhookRepo := repositories.NewWebhookRepository()
+	releaser := lifecycle.NewClaimReleaser(cfg, claimRepo, recordRepo, waitlistRepo, notifier)
+
+	events.Subscribe(webhooks.NewDispatcher(webhookRepo).Enqueue)
+
+	broker := stream.NewBroker()
+	relay, err := stream.NewRelay(cfg, broker)
+	if err != nil {
+		logging.Fatal("Failed to start event relay", "error", err)
+	}
+	events.Subscribe(relay.Publish)
+
+	scheduler := lifecycle.NewScheduler()
+	secretReload := lifecycle.NewSecretReloadJob(cfg)
+	scheduler.Every(cfg.SecretsReloadInterval, secretReload)
+	if cfg.ClaimExpiryEnabled {
+		scheduler.Every(cfg.ClaimExpiryInterval, lifecycle.NewClaimExpiryJob(cfg, claimRepo, releaser, notifier))
+	}
+	if cfg.ClaimLivenessEnabled {
+		scheduler.Every(cfg.ClaimLivenessInterval, lifecycle.NewClaimLivenessJob(
+			cfg,
+			claimRepo,
+			recordRepo,
+			releaser,
+			services.NewLivenessProber(cfg.ClaimLivenessProbeTimeout, lifecycle.CheckLivenessTarget),
+			notifier,
+		))
+	}
+	scheduler.Every(cfg.WaitlistInterval, lifecycle.NewWaitlistJob(waitlistRepo, releaser))
+	scheduler.Every(cfg.SuspensionExpiryInterval, lifecycle.NewSuspensionExpiryJob(userRepo, recordRepo, lifecycle.NewUserSuspender(userRepo, recordRepo, notifier)))
+	scheduler.Every(cfg.WebhookDeliveryInterval, webhooks.NewDeliveryJob(
+		cfg,
+		webhookRepo,
+		webhooks.NewSender(cfg.WebhookTimeout, cfg.WebhookAllowPrivateTargets),
+	))
+	if cfg.EmailEnabled() {
+		scheduler.Every(cfg.EmailDeliveryInterval, notifications.NewEmailDeliveryJob(
+			cfg,
+			repositories.NewNotificationRepository(),
+			services.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom, cfg.SMTPSecurity, cfg.SMTPTimeout),
+		))
 	}
+	scheduler.Start()
 
-	app := fiber.New()
+	// SIGHUP reloads secrets straight away, e.g. from ExecReload= after they
+	// were rotated.
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			slog.Info("Reloading secrets")
+			if err := secretReload.Run(context.Background()); err != nil {
+				slog.Error("Error reloading secrets", "error", err)
+			}
+		}
+	}()
 
-	app.Get("/health", func(ctx *fiber.Ctx) error {
-		return ctx.JSON(fiber.Map{
-			"status": "healthy",
-		})
+	app := fiber.New(fiber.Config{
+		ErrorHandler: problem.ErrorHandler,
 	})
 
-	app.Use(logger.New())
+	routes.InitHealthRouter(app, cfg)
+
+	app.Use(middleware.ClientIPMiddleware(cfg))
+	app.Use(middleware.RequestIDMiddleware())
+	app.Use(middleware.TracingMiddleware())
+	app.Use(middleware.TimeoutMiddleware(cfg.RequestTimeout))
+	app.Use(middleware.MetricsMiddleware())
+	app.Use(middleware.RequestLoggerMiddleware())
 	app.Use(middleware.CorsMiddleware(cfg))
 	// app.Use(middleware.LinuxOnlyMiddleware())
 
-	routes.InitAuthRouter(app)
-	routes.InitRecordRouter(app)
+	routes.InitMetricsRouter(app, cfg)
+	routes.InitDocsRouter(app)
+	routes.InitAPIRouter(app, cfg, broker)
+
+	ln, activated, err := listen("0.0.0.0:" + cfg.Port)
+	if err != nil {
+		logging.Fatal("Failed to listen", "error", err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.Listener(ln)
+	}()
+	slog.Info("Server starting", "address", ln.Addr().String(), "socket_activated", activated)
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	select {
+	case err := <-serveErr:
+		logging.Fatal("Server stopped", "error", err)
+	case <-signals.Done():
+	}
+	stopSignals()
+
+	slog.Info("Shutting down", "timeout", cfg.ShutdownTimeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Event streams only end when told to, so close them before waiting for
+	// requests to drain.
+	broker.Close()
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		slog.Error("Error draining requests", "error", err)
+	}
+	if err := scheduler.Shutdown(ctx); err != nil {
+		slog.Error("Error stopping background jobs", "error", err)
+	}
+	if err := relay.Close(); err != nil {
+		slog.Error("Error closing event relay", "error", err)
+	}
+	if err := database.Close(); err != nil {
+		slog.Error("Error closing database", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
 
-	log.Printf("Server starting on port %s", cfg.Port)
-	log.Fatal(app.Listen("0.0.0.0:" + cfg.Port))
+	slog.Info("Server stopped")
 }
@@ -3,8 +3,9 @@ package main
 import (
 	"btwarch/config"
 	"btwarch/database"
+	"btwarch/logging"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -14,23 +15,30 @@ import (
 )
 
 func main() {
-
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Error loading .env file: %v", err)
+	envErr := godotenv.Load()
+	// Migrations only need the database, so the configuration is parsed but
+	// not validated as a whole.
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logging.Setup(cfg)
+	if envErr != nil {
+		slog.Warn("Error loading .env file", "error", envErr)
 	}
+
 	if len(os.Args) > 1 && os.Args[1] == "status" {
-		showStatus()
+		showStatus(cfg)
 		return
 	}
 
-	runMigrations()
+	runMigrations(cfg)
 }
 
-func runMigrations() {
-	cfg := config.LoadConfig()
-
-	if err := database.Connect(cfg.DatabaseURL); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+func runMigrations(cfg *config.Config) {
+	if err := database.Connect(cfg.DatabaseURL, 0); err != nil {
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
 	defer database.Close()
 
@@ -39,20 +47,18 @@ func runMigrations() {
 		migrationsDir = "./database/migrations"
 	}
 
-	log.Printf("Running migrations from: %s", migrationsDir)
+	slog.Info("Running migrations", "dir", migrationsDir)
 
 	if err := database.RunMigrations(database.DB, migrationsDir); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		logging.Fatal("Failed to run migrations", "error", err)
 	}
 
-	log.Println("Migrations completed successfully")
+	slog.Info("Migrations completed successfully")
 }
 
-func showStatus() {
-	cfg := config.LoadConfig()
-
-	if err := database.Connect(cfg.DatabaseURL); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+func showStatus(cfg *config.Config) {
+	if err := database.Connect(cfg.DatabaseURL, 0); err != nil {
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
 	defer database.Close()
 
@@ -66,12 +72,12 @@ func showStatus() {
 
 	appliedMigrations, err := database.GetAppliedMigrations(database.DB)
 	if err != nil {
-		log.Fatalf("Failed to get applied migrations: %v", err)
+		logging.Fatal("Failed to get applied migrations", "error", err)
 	}
 
 	migrationFiles, err := database.ReadMigrationFiles(migrationsDir)
 	if err != nil {
-		log.Fatalf("Failed to read migration files: %v", err)
+		logging.Fatal("Failed to read migration files", "error", err)
 	}
 
 	sort.Strings(migrationFiles)
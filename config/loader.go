@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Where a setting's value came from.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// Setting is the resolved value of one configuration key.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Settings returns every configuration key with its value and source, in the
+// order they are read. Secrets are masked, so the result is safe to print.
+func (c *Config) Settings() []Setting {
+	return c.settings
+}
+
+// Error lists every problem found in a configuration.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// loader reads typed values from the environment, then the config file, and
+// collects a problem for every value it cannot parse instead of silently
+// using the default.
+type loader struct {
+	path     string
+	file     map[string]string
+	used     map[string]bool
+	settings []Setting
+	problems []string
+}
+
+func (l *loader) lookup(key string) (string, string, bool) {
+	if l.used == nil {
+		l.used = map[string]bool{}
+	}
+	l.used[key] = true
+
+	if value := os.Getenv(key); value != "" {
+		return value, SourceEnv, true
+	}
+	if value, ok := l.file[key]; ok && value != "" {
+		return value, SourceFile, true
+	}
+	return "", SourceDefault, false
+}
+
+func (l *loader) record(key, value, source string) {
+	l.settings = append(l.settings, Setting{Key: key, Value: mask(key, value), Source: source})
+}
+
+func (l *loader) problem(key, value, want string) {
+	l.problems = append(l.problems, fmt.Sprintf("%s: %q is not %s", key, value, want))
+}
+
+func (l *loader) string(key, defaultValue string) string {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		value = defaultValue
+	}
+	l.record(key, value, source)
+	return value
+}
+
+func (l *loader) strings(key string, defaultValue []string) []string {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		l.record(key, strings.Join(defaultValue, ","), source)
+		return defaultValue
+	}
+	l.record(key, value, source)
+
+	values := strings.Split(value, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+
+	return values
+}
+
+func (l *loader) bool(key string, defaultValue bool) bool {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		l.record(key, strconv.FormatBool(defaultValue), source)
+		return defaultValue
+	}
+	l.record(key, value, source)
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		l.problem(key, value, "true or false")
+		return defaultValue
+	}
+	return parsed
+}
+
+func (l *loader) int(key string, defaultValue int) int {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		l.record(key, strconv.Itoa(defaultValue), source)
+		return defaultValue
+	}
+	l.record(key, value, source)
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		l.problem(key, value, "a whole number")
+		return defaultValue
+	}
+	return parsed
+}
+
+func (l *loader) float(key string, defaultValue float64) float64 {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		l.record(key, strconv.FormatFloat(defaultValue, 'g', -1, 64), source)
+		return defaultValue
+	}
+	l.record(key, value, source)
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.problem(key, value, "a number")
+		return defaultValue
+	}
+	return parsed
+}
+
+func (l *loader) duration(key string, defaultValue time.Duration) time.Duration {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		l.record(key, defaultValue.String(), source)
+		return defaultValue
+	}
+	l.record(key, value, source)
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		l.problem(key, value, "a duration such as 30s or 5m")
+		return defaultValue
+	}
+	return parsed
+}
+
+// rateLimit reads a limit written as "<requests>/<period>", e.g. "30/1m".
+// "off" disables the limit.
+func (l *loader) rateLimit(key string, defaultValue RateLimit) RateLimit {
+	value, source, ok := l.lookup(key)
+	if !ok {
+		l.record(key, fmt.Sprintf("%d/%s", defaultValue.Requests, defaultValue.Period), source)
+		return defaultValue
+	}
+	l.record(key, value, source)
+
+	if value == "off" {
+		return RateLimit{}
+	}
+
+	requests, period, ok := strings.Cut(value, "/")
+	parsedRequests, requestsErr := strconv.Atoi(strings.TrimSpace(requests))
+	parsedPeriod, periodErr := time.ParseDuration(strings.TrimSpace(period))
+	if !ok || requestsErr != nil || periodErr != nil || parsedRequests < 0 || parsedPeriod < 0 {
+		l.problem(key, value, `a limit such as "30/1m" or "off"`)
+		return defaultValue
+	}
+
+	return RateLimit{Requests: parsedRequests, Period: parsedPeriod}
+}
+
+// checkFile reports keys in the config file that are not settings, which are
+// almost always typos.
+func (l *loader) checkFile() {
+	var unknown []string
+	for key := range l.file {
+		if !l.used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, key := range unknown {
+		l.problems = append(l.problems, fmt.Sprintf("%s: unknown setting in %s", key, l.path))
+	}
+}
+
+var (
+	secretKeys     = []string{"SECRET", "TOKEN", "PASSWORD"}
+	dsnPasswordRe  = regexp.MustCompile(`password=\S+`)
+	maskedSecret   = "********"
+	databaseURLKey = "DATABASE_URL"
+)
+
+func mask(key, value string) string {
+	if value == "" {
+		return value
+	}
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return maskedSecret
+		}
+	}
+	if key == databaseURLKey {
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+		return dsnPasswordRe.ReplaceAllString(value, "password="+maskedSecret)
+	}
+	return value
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SourceSecretFile marks a secret read from a file, either one named by a
+// <KEY>_FILE setting or a systemd credential.
+const SourceSecretFile = "secret file"
+
+// maxSecretHistory bounds how many replaced values a secret remembers.
+const maxSecretHistory = 8
+
+// Secret is a credential that is either set directly or read from a file. A
+// secret read from a file is re-read by Reload, so it can be rotated without
+// restarting. The values it replaced stay available through History.
+type Secret struct {
+	path string
+
+	mu      sync.RWMutex
+	value   string
+	history []ReplacedValue
+}
+
+// ReplacedValue is a value a secret held until it was rotated at RotatedAt.
+type ReplacedValue struct {
+	Value     string
+	RotatedAt time.Time
+}
+
+// NewSecret returns a secret with a fixed value.
+func NewSecret(value string) *Secret {
+	return &Secret{value: value}
+}
+
+// Value returns the current value. A nil secret is empty.
+func (s *Secret) Value() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.value
+}
+
+// History returns the values replaced by the last rotations, most recent
+// first. Only the last maxSecretHistory are kept.
+func (s *Secret) History() []ReplacedValue {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.history)
+}
+
+// Path returns the file the secret is read from, or "" for a fixed value.
+func (s *Secret) Path() string {
+	if s == nil {
+		return ""
+	}
+	return s.path
+}
+
+// Reload re-reads a secret kept in a file and reports whether its value
+// changed. An empty file is rejected so a half-written rotation cannot log
+// everyone out; the current value is kept.
+func (s *Secret) Reload() (bool, error) {
+	if s == nil || s.path == "" {
+		return false, nil
+	}
+
+	value, err := readSecretFile(s.path)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value == s.value {
+		return false, nil
+	}
+	// A value rotated back into use is no longer a previous one.
+	history := slices.DeleteFunc(s.history, func(replaced ReplacedValue) bool {
+		return replaced.Value == value
+	})
+	history = slices.Insert(history, 0, ReplacedValue{Value: s.value, RotatedAt: time.Now()})
+	if len(history) > maxSecretHistory {
+		history = history[:maxSecretHistory]
+	}
+	s.value, s.history = value, history
+	return true, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading secret: %w", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return value, nil
+}
+
+// secret reads a credential from, in order of precedence, the file named by
+// <KEY>_FILE, the systemd credential named after the key in lower case (see
+// LoadCredential= in systemd.exec(5)), or the plain <KEY> setting.
+func (l *loader) secret(key string) *Secret {
+	path, _, ok := l.lookup(key + "_FILE")
+	if !ok {
+		path = credentialPath(key)
+	}
+
+	if path != "" {
+		// A plain value set alongside the file is ignored.
+		l.lookup(key)
+
+		value, err := readSecretFile(path)
+		if err != nil {
+			l.problems = append(l.problems, fmt.Sprintf("%s: %v", key, err))
+		}
+		l.settings = append(l.settings, Setting{Key: key, Value: path, Source: SourceSecretFile})
+		return &Secret{path: path, value: value}
+	}
+
+	return NewSecret(l.string(key, ""))
+}
+
+// credentialPath returns the systemd credential file for key, if one was
+// passed to the service.
+func credentialPath(key string) string {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return ""
+	}
+
+	path := filepath.Join(dir, strings.ToLower(key))
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// Secrets returns the credentials that can be rotated by reloading them.
+func (c *Config) Secrets() map[string]*Secret {
+	return map[string]*Secret{
+		"GITHUB_CLIENT_SECRET": c.GitHubClientSecret,
+		"CLOUDFLARE_API_TOKEN": c.CloudFlareApiToken,
+		"JWT_SECRET":           c.JWTSecret,
+		"JWT_PREVIOUS_SECRET":  c.JWTPreviousSecret,
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minJWTSecretLength is the shortest JWT_SECRET that does not draw a warning,
+// in bytes. HS256 keys shorter than the hash output weaken the signature.
+const minJWTSecretLength = 32
+
+// Validate checks that the configuration can run the server: credentials are
+// set, enumerations hold a known value and addresses parse. All problems are
+// reported together in an *Error.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.required("GITHUB_CLIENT_ID", c.GitHubClientID)
+	v.required("GITHUB_CLIENT_SECRET", c.GitHubClientSecret.Value())
+	v.url("GITHUB_REDIRECT_URL", c.GitHubRedirectURL)
+
+	v.required("CLOUDFLARE_ZONE_ID", c.CloudFlareZoneId)
+	v.required("CLOUDFLARE_API_TOKEN", c.CloudFlareApiToken.Value())
+
+	v.required("JWT_SECRET", c.JWTSecret.Value())
+	if previous := c.JWTPreviousSecret.Value(); previous != "" && previous == c.JWTSecret.Value() {
+		v.add("JWT_PREVIOUS_SECRET: must differ from JWT_SECRET")
+	}
+
+	v.required("DATABASE_URL", c.DatabaseURL)
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		v.add("PORT: %q is not a port number", c.Port)
+	}
+	v.positive("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+
+	v.oneOf("COOKIE_SAME_SITE", strings.ToLower(c.CookieSameSite), "lax", "strict", "none")
+	if strings.EqualFold(c.CookieSameSite, "none") && !c.CookieSecure {
+		v.add("COOKIE_SAME_SITE: none requires COOKIE_SECURE=true")
+	}
+
+	if v.required("PARENT_DOMAIN", c.ParentDomain) && strings.Trim(c.ParentDomain, ".") != c.ParentDomain {
+		v.add("PARENT_DOMAIN: %q must not start or end with a dot", c.ParentDomain)
+	}
+
+	for _, id := range c.AdminGitHubIDs {
+		if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+			v.add("ADMIN_GITHUB_IDS: %q is not a GitHub user ID", id)
+		}
+	}
+
+	v.positive("WEBHOOK_TIMEOUT", c.WebhookTimeout)
+	v.atLeast("WEBHOOK_MAX_ATTEMPTS", c.WebhookMaxAttempts, 1)
+	v.oneOf("EVENTS_BACKEND", c.EventsBackend, "memory", "postgres")
+	v.positive("EVENTS_HEARTBEAT_INTERVAL", c.EventsHeartbeatInterval)
+
+	if c.EmailEnabled() {
+		v.oneOf("SMTP_SECURITY", c.SMTPSecurity, "starttls", "tls", "none")
+		v.required("SMTP_FROM", c.SMTPFrom)
+		v.positive("SMTP_TIMEOUT", c.SMTPTimeout)
+		v.atLeast("EMAIL_MAX_ATTEMPTS", c.EmailMaxAttempts, 1)
+		v.atLeast("EMAIL_VERIFY_USER_LIMIT", c.EmailVerifyUserLimit, 1)
+		v.atLeast("EMAIL_VERIFY_RECIPIENT_LIMIT", c.EmailVerifyRecipientLimit, 1)
+	}
+
+	v.url("APP_URL", c.AppURL)
+	v.url("PUBLIC_URL", c.PublicURL)
+
+	v.prefixes("TRUSTED_PROXIES", c.TrustedProxies)
+	v.prefixes("METRICS_ALLOWED_IPS", c.MetricsAllowedIPs)
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		v.add("LOG_LEVEL: %q is not one of debug, info, warn, error", c.LogLevel)
+	}
+	v.oneOf("LOG_FORMAT", strings.ToLower(c.LogFormat), "json", "text")
+
+	v.oneOf("TRACING_EXPORTER", strings.ToLower(c.TracingExporter), "none", "otlp")
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		v.add("TRACING_SAMPLE_RATIO: %v is not between 0 and 1", c.TracingSampleRatio)
+	}
+
+	v.positive("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+	v.oneOf("RATE_LIMIT_BACKEND", c.RateLimitBackend, "memory", "postgres")
+
+	if c.TargetBlocklistFile != "" {
+		if _, err := os.Stat(c.TargetBlocklistFile); err != nil {
+			v.add("TARGET_BLOCKLIST_FILE: %v", err)
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &Error{Problems: v.problems}
+	}
+	return nil
+}
+
+// Warnings lists settings the server runs with but should not, such as a
+// short JWT_SECRET. They are meant to become errors in a later release.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if secret := c.JWTSecret.Value(); secret != "" && len(secret) < minJWTSecretLength {
+		warnings = append(warnings, fmt.Sprintf("JWT_SECRET: should be at least %d bytes, e.g. the output of `openssl rand -hex 32`", minJWTSecretLength))
+	}
+	return warnings
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(key, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add("%s: is required", key)
+		return false
+	}
+	return true
+}
+
+func (v *validator) positive(key string, value time.Duration) {
+	if value <= 0 {
+		v.add("%s: must be greater than zero", key)
+	}
+}
+
+func (v *validator) atLeast(key string, value, min int) {
+	if value < min {
+		v.add("%s: must be at least %d", key, min)
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add("%s: %q is not one of %s", key, value, strings.Join(allowed, ", "))
+}
+
+func (v *validator) url(key, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add("%s: %q is not an http(s) URL", key, value)
+	}
+}
+
+func (v *validator) prefixes(key string, values []string) {
+	for _, value := range values {
+		if _, err := netip.ParsePrefix(value); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(value); err == nil {
+			continue
+		}
+		v.add("%s: %q is not an IP address or CIDR range", key, value)
+	}
+}
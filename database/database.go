@@ -2,11 +2,23 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
+	"github.com/uptrace/opentelemetry-go-extra/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
 )
 
 type User struct {
@@ -16,8 +28,21 @@ type User struct {
 	Email       string    `json:"email"`
 	AvatarURL   string    `json:"avatar_url"`
 	AccessToken string    `json:"-"`
-	CreatedAt   string    `json:"created_at"`
-	UpdatedAt   string    `json:"updated_at"`
+	Role        string    `json:"role"`
+
+	// Suspended is true while a suspension is in effect, i.e. suspended_at is
+	// set and suspended_until has not passed yet.
+	Suspended        bool    `json:"suspended"`
+	SuspendedAt      *string `json:"suspended_at"`
+	SuspendedUntil   *string `json:"suspended_until"`
+	SuspensionReason *string `json:"suspension_reason"`
+
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
 
 type Record struct {
@@ -29,23 +54,169 @@ type Record struct {
 	TTL                int       `json:"ttl"`
 	IsActive           bool      `json:"is_active"`
 	CloudflareRecordID *string   `json:"cloudflare_record_id"`
+	Suspended          bool      `json:"suspended"`
+	ModeratedAt        *string   `json:"moderated_at"`
 	CreatedAt          string    `json:"created_at"`
 	UpdatedAt          string    `json:"updated_at"`
 }
 
+const (
+	ClaimStatusActive   = "active"
+	ClaimStatusWarned   = "warned"
+	ClaimStatusCooldown = "cooldown"
+)
+
 type SubdomainClaim struct {
+	ID              uuid.UUID `json:"id"`
+	UserId          uuid.UUID `json:"user_id"`
+	SubdomainName   string    `json:"subdomain_name"`
+	DisplayName     string    `json:"display_name"`
+	Skeleton        string    `json:"-"`
+	IsPublic        bool      `json:"is_public"`
+	Description     string    `json:"description"`
+	Status          string    `json:"status"`
+	LastActivityAt  *string   `json:"last_activity_at"`
+	StatusChangedAt *string   `json:"status_changed_at"`
+
+	VerifyBy              *string `json:"verify_by"`
+	VerifiedAt            *string `json:"verified_at"`
+	LastVerificationAt    *string `json:"last_verification_at"`
+	LastVerificationError *string `json:"last_verification_error"`
+
+	// ModeratedAt is set while an administrator keeps the claim's records
+	// from being enabled.
+	ModeratedAt *string `json:"moderated_at"`
+
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type DirectoryEntry struct {
+	SubdomainName string `json:"subdomain_name"`
+	DisplayName   string `json:"display_name"`
+	Domain        string `json:"domain"`
+	Link          string `json:"link"`
+	Description   string `json:"description"`
+	Username      string `json:"username"`
+	AvatarURL     string `json:"avatar_url"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type WaitlistEntry struct {
 	ID            uuid.UUID `json:"id"`
 	UserId        uuid.UUID `json:"user_id"`
 	SubdomainName string    `json:"subdomain_name"`
+	Position      int       `json:"position"`
 	CreatedAt     string    `json:"created_at"`
-	UpdatedAt     string    `json:"updated_at"`
+}
+
+type SubdomainReservation struct {
+	ID            uuid.UUID `json:"id"`
+	UserId        uuid.UUID `json:"user_id"`
+	SubdomainName string    `json:"subdomain_name"`
+	ExpiresAt     string    `json:"expires_at"`
+	CreatedAt     string    `json:"created_at"`
+}
+
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusActioned  = "actioned"
+	ReportStatusDismissed = "dismissed"
+)
+
+type AbuseReport struct {
+	ID             uuid.UUID  `json:"id"`
+	SubdomainName  string     `json:"subdomain_name"`
+	Category       string     `json:"category"`
+	Evidence       string     `json:"evidence"`
+	ReporterEmail  *string    `json:"reporter_email"`
+	ReporterIP     *string    `json:"reporter_ip"`
+	Status         string     `json:"status"`
+	ResolvedBy     *uuid.UUID `json:"resolved_by"`
+	ResolutionNote *string    `json:"resolution_note"`
+	ResolvedAt     *string    `json:"resolved_at"`
+	CreatedAt      string     `json:"created_at"`
+	UpdatedAt      string     `json:"updated_at"`
+}
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+type Webhook struct {
+	ID        uuid.UUID `json:"id"`
+	UserId    uuid.UUID `json:"user_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt string    `json:"created_at"`
+	UpdatedAt string    `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID                 uuid.UUID       `json:"id"`
+	WebhookID          uuid.UUID       `json:"webhook_id"`
+	EventID            uuid.UUID       `json:"event_id"`
+	Event              string          `json:"event"`
+	Payload            json.RawMessage `json:"payload"`
+	Status             string          `json:"status"`
+	Attempts           int             `json:"attempts"`
+	NextAttemptAt      string          `json:"next_attempt_at"`
+	LastResponseStatus *int            `json:"last_response_status"`
+	LastError          *string         `json:"last_error"`
+	DeliveredAt        *string         `json:"delivered_at"`
+	CreatedAt          string          `json:"created_at"`
+	UpdatedAt          string          `json:"updated_at"`
+}
+
+// NotificationPreferences are a user's email notification settings. Users
+// without a row get every category at their GitHub email address.
+type NotificationPreferences struct {
+	UserId                  uuid.UUID `json:"user_id"`
+	DisabledCategories      []string  `json:"disabled_categories"`
+	EmailOverride           *string   `json:"email_override"`
+	EmailOverrideVerifiedAt *string   `json:"email_override_verified_at"`
+	PendingEmail            *string   `json:"pending_email"`
+	PendingEmailExpiresAt   *string   `json:"pending_email_expires_at"`
+	CreatedAt               string    `json:"created_at"`
+	UpdatedAt               string    `json:"updated_at"`
+}
+
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed"
+)
+
+type OutgoingEmail struct {
+	ID            uuid.UUID  `json:"id"`
+	UserId        *uuid.UUID `json:"user_id"`
+	ToAddress     string     `json:"to_address"`
+	Event         string     `json:"event"`
+	Subject       string     `json:"subject"`
+	TextBody      string     `json:"text_body"`
+	HTMLBody      string     `json:"html_body"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt string     `json:"next_attempt_at"`
+	LastError     *string    `json:"last_error"`
+	SentAt        *string    `json:"sent_at"`
+	CreatedAt     string     `json:"created_at"`
+	UpdatedAt     string     `json:"updated_at"`
 }
 
 var DB *sql.DB
 
-func Connect(databaseURL string) error {
+// Connect opens the database pool. A non-zero statementTimeout is set as the
+// session's statement_timeout, so the server cancels any query that runs
+// longer, unless the URL already sets one. Migrations lift it for their own
+// transaction.
+func Connect(databaseURL string, statementTimeout time.Duration) error {
 	var err error
-	DB, err = sql.Open("postgres", databaseURL)
+	DB, err = otelsql.Open("postgres", withStatementTimeout(databaseURL, statementTimeout), otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 	if err != nil {
 		return fmt.Errorf("error opening database: %v", err)
 	}
@@ -54,17 +225,43 @@ func Connect(databaseURL string) error {
 		return fmt.Errorf("error connecting to database: %v", err)
 	}
 
-	log.Println("Connected to PostgreSQL database")
+	slog.Info("Connected to PostgreSQL database")
 	return nil
 }
 
+// withStatementTimeout adds statement_timeout to a postgres:// URL or a
+// key=value connection string. lib/pq passes unknown keys on to the server as
+// run-time parameters.
+func withStatementTimeout(databaseURL string, timeout time.Duration) string {
+	if timeout <= 0 || strings.Contains(databaseURL, "statement_timeout") {
+		return databaseURL
+	}
+	millis := strconv.FormatInt(timeout.Milliseconds(), 10)
+
+	if strings.HasPrefix(databaseURL, "postgres://") || strings.HasPrefix(databaseURL, "postgresql://") {
+		u, err := url.Parse(databaseURL)
+		if err != nil {
+			return databaseURL
+		}
+		query := u.Query()
+		query.Set("statement_timeout", millis)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	return strings.TrimSpace(databaseURL + " statement_timeout=" + millis)
+}
+
+// MigrationsDir is where the migration files are read from, relative to the
+// working directory.
+const MigrationsDir = "./database/migrations"
+
 func InitTables() error {
-	migrationsDir := "./database/migrations"
-	if err := RunMigrations(DB, migrationsDir); err != nil {
+	if err := RunMigrations(DB, MigrationsDir); err != nil {
 		return fmt.Errorf("error running migrations: %v", err)
 	}
 
-	log.Println("Database tables initialized via migrations")
+	slog.Info("Database tables initialized via migrations")
 	return nil
 }
 
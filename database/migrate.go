@@ -4,7 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -38,7 +38,7 @@ func RunMigrations(db *sql.DB, migrationsDir string) error {
 	for _, file := range migrationFiles {
 		version := ExtractVersion(file)
 		if _, exists := appliedMigrations[version]; exists {
-			log.Printf("Migration %s already applied, skipping", version)
+			slog.Debug("Migration already applied, skipping", "version", version)
 			continue
 		}
 
@@ -47,7 +47,7 @@ func RunMigrations(db *sql.DB, migrationsDir string) error {
 		}
 	}
 
-	log.Println("All migrations completed successfully")
+	slog.Info("All migrations completed successfully")
 	return nil
 }
 
@@ -142,6 +142,12 @@ func applyMigration(db *sql.DB, filePath, version string) error {
 	}
 	defer tx.Rollback()
 
+	// The pool's statement_timeout is meant for request queries; a migration or
+	// backfill may legitimately run longer.
+	if _, err := tx.Exec("SET LOCAL statement_timeout = 0"); err != nil {
+		return fmt.Errorf("failed to disable statement timeout: %v", err)
+	}
+
 	_, err = tx.Exec(string(content))
 	if err != nil {
 		return fmt.Errorf("failed to execute migration SQL: %v", err)
@@ -159,6 +165,6 @@ func applyMigration(db *sql.DB, filePath, version string) error {
 		return fmt.Errorf("failed to commit migration: %v", err)
 	}
 
-	log.Printf("Applied migration %s: %s", version, name)
+	slog.Info("Applied migration", "version", version, "name", name)
 	return nil
 }
@@ -0,0 +1,9 @@
+-- Migration: 009_add_claim_lifecycle.sql
+-- Description: Track claim activity and lifecycle state for inactive claim expiry
+
+ALTER TABLE subdomain_claims
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active',
+    ADD COLUMN last_activity_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN status_changed_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_subdomain_claims_status ON subdomain_claims(status);
@@ -0,0 +1,13 @@
+-- Migration: 010_add_claim_liveness.sql
+-- Description: Track proof-of-liveness verification for subdomain claims
+
+ALTER TABLE subdomain_claims
+    ADD COLUMN verify_by TIMESTAMP,
+    ADD COLUMN verified_at TIMESTAMP,
+    ADD COLUMN last_verification_at TIMESTAMP,
+    ADD COLUMN last_verification_error TEXT;
+
+-- Claims made before the liveness requirement are treated as verified
+UPDATE subdomain_claims SET verified_at = CURRENT_TIMESTAMP WHERE verified_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_subdomain_claims_verified_at ON subdomain_claims(verified_at);
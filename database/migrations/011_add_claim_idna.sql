@@ -0,0 +1,24 @@
+-- Migration: 011_add_claim_idna.sql
+-- Description: Store display names and confusable skeletons for internationalized subdomain claims
+
+ALTER TABLE subdomain_claims
+    ADD COLUMN display_name VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN skeleton VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Lowercase claims whose lowercase form is not shared with another claim.
+-- Claims that only differ by case are left for manual resolution.
+UPDATE subdomain_claims c
+SET subdomain_name = LOWER(c.subdomain_name)
+WHERE c.subdomain_name <> LOWER(c.subdomain_name)
+AND NOT EXISTS (
+    SELECT 1 FROM subdomain_claims o
+    WHERE LOWER(o.subdomain_name) = LOWER(c.subdomain_name)
+    AND o.id <> c.id
+);
+
+UPDATE records SET record_name = LOWER(record_name) WHERE record_name <> LOWER(record_name);
+
+-- Existing claims are ASCII-only, so their display name and skeleton are the name itself
+UPDATE subdomain_claims SET display_name = subdomain_name, skeleton = LOWER(subdomain_name);
+
+CREATE INDEX IF NOT EXISTS idx_subdomain_claims_skeleton ON subdomain_claims(skeleton);
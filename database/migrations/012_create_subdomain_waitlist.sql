@@ -0,0 +1,25 @@
+-- Migration: 012_create_subdomain_waitlist.sql
+-- Description: Create waitlist and reservation tables for taken subdomain names
+
+-- Create subdomain_waitlist table
+CREATE TABLE IF NOT EXISTS subdomain_waitlist (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subdomain_name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, subdomain_name)
+);
+
+-- Create subdomain_reservations table
+CREATE TABLE IF NOT EXISTS subdomain_reservations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subdomain_name VARCHAR(255) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for waitlist and reservations tables
+CREATE INDEX IF NOT EXISTS idx_subdomain_waitlist_subdomain_name ON subdomain_waitlist(subdomain_name, created_at);
+CREATE INDEX IF NOT EXISTS idx_subdomain_waitlist_user_id ON subdomain_waitlist(user_id);
+CREATE INDEX IF NOT EXISTS idx_subdomain_reservations_expires_at ON subdomain_reservations(expires_at);
@@ -0,0 +1,8 @@
+-- Migration: 013_add_claim_directory.sql
+-- Description: Add opt-in public directory listing fields to subdomain claims
+
+ALTER TABLE subdomain_claims
+    ADD COLUMN is_public BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN description VARCHAR(280) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_subdomain_claims_public_created_at ON subdomain_claims(created_at DESC) WHERE is_public;
@@ -0,0 +1,8 @@
+-- Migration: 014_add_user_roles.sql
+-- Description: Add roles and suspension flag to users for admin moderation
+
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user',
+    ADD COLUMN suspended_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
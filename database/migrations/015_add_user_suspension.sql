@@ -0,0 +1,12 @@
+-- Migration: 015_add_user_suspension.sql
+-- Description: Record suspension reason and expiry, and flag records deactivated by a suspension
+
+ALTER TABLE users
+    ADD COLUMN suspension_reason TEXT,
+    ADD COLUMN suspended_until TIMESTAMP;
+
+ALTER TABLE records
+    ADD COLUMN suspended BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_users_suspended_until ON users(suspended_until) WHERE suspended_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_records_suspended ON records(user_id) WHERE suspended;
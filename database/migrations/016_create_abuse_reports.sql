@@ -0,0 +1,22 @@
+-- Migration: 016_create_abuse_reports.sql
+-- Description: Create abuse_reports table for the moderation queue
+
+-- Create abuse_reports table
+CREATE TABLE IF NOT EXISTS abuse_reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subdomain_name VARCHAR(255) NOT NULL,
+    category VARCHAR(20) NOT NULL,
+    evidence TEXT NOT NULL,
+    reporter_email VARCHAR(255),
+    reporter_ip VARCHAR(45),
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolution_note TEXT,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for abuse_reports table
+CREATE INDEX IF NOT EXISTS idx_abuse_reports_status ON abuse_reports(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_abuse_reports_subdomain_name ON abuse_reports(subdomain_name);
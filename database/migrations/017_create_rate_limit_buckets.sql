@@ -0,0 +1,14 @@
+-- Migration: 017_create_rate_limit_buckets.sql
+-- Description: Create token bucket table for the shared Postgres rate limit backend
+
+-- Create rate_limit_buckets table
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Create indexes for rate_limit_buckets table
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_expires_at ON rate_limit_buckets(expires_at);
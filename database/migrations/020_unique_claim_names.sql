@@ -0,0 +1,37 @@
+-- Migration: 020_unique_claim_names.sql
+-- Description: Enforce case-insensitive uniqueness of claimed subdomain names and fold digit/letter look-alikes into skeletons
+
+-- 011 left claims that only differ by case for manual resolution. Dropping all
+-- but one here would leave the other owners' records, and their Cloudflare
+-- records, live under a name they no longer hold, so the migration stops and
+-- lists them instead. Release the claims that should go through
+-- POST /v1/admin/claims/{id}/release, which deletes their records and notifies
+-- the owner, then run it again.
+DO $$
+DECLARE
+    conflicts TEXT;
+BEGIN
+    SELECT string_agg(name || ' (' || claims || ')', '; ' ORDER BY name)
+    INTO conflicts
+    FROM (
+        SELECT LOWER(subdomain_name) AS name,
+               string_agg(subdomain_name || ' ' || id::text || ' owned by ' || user_id::text, ', ' ORDER BY created_at, id) AS claims
+        FROM subdomain_claims
+        GROUP BY LOWER(subdomain_name)
+        HAVING COUNT(*) > 1
+    ) duplicates;
+
+    IF conflicts IS NOT NULL THEN
+        RAISE EXCEPTION 'subdomain claims that only differ by case: %', conflicts
+            USING HINT = 'Release all but one claim per name, then run the migration again.';
+    END IF;
+END $$;
+
+UPDATE subdomain_claims
+SET subdomain_name = LOWER(subdomain_name), display_name = LOWER(display_name)
+WHERE subdomain_name <> LOWER(subdomain_name);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subdomain_claims_subdomain_name_lower ON subdomain_claims(LOWER(subdomain_name));
+
+-- Same folding as lookalikes in utils/idna.go.
+UPDATE subdomain_claims SET skeleton = TRANSLATE(skeleton, '01', 'ol');
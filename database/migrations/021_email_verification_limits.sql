@@ -0,0 +1,5 @@
+-- Migration: 021_email_verification_limits.sql
+-- Description: Index verification emails by sender and recipient for the per-user and per-recipient limits
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_verify_user ON email_outbox(user_id, created_at) WHERE event = 'email.verify';
+CREATE INDEX IF NOT EXISTS idx_email_outbox_verify_recipient ON email_outbox(LOWER(to_address), created_at) WHERE event = 'email.verify';
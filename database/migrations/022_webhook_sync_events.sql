@@ -0,0 +1,7 @@
+-- Migration: 022_webhook_sync_events.sql
+-- Description: Remove the stream-only record.synced and record.sync_failed events from webhook subscriptions
+
+UPDATE webhooks
+SET events = array_remove(array_remove(events, 'record.synced'), 'record.sync_failed'),
+    updated_at = CURRENT_TIMESTAMP
+WHERE events && ARRAY['record.synced', 'record.sync_failed']::TEXT[];
@@ -0,0 +1,8 @@
+-- Migration: 023_moderation_locks.sql
+-- Description: Record when an administrator took down a record or a whole claim, so that the owner cannot bring it back
+
+ALTER TABLE records
+    ADD COLUMN moderated_at TIMESTAMP;
+
+ALTER TABLE subdomain_claims
+    ADD COLUMN moderated_at TIMESTAMP;
@@ -0,0 +1,58 @@
+#!/usr/bin/env bash
+# Creates the secret files btwarch.service reads (JWT_SECRET_FILE and the
+# like) in /etc/btwarch, readable by root and the service's group only.
+#
+#   sudo deploy/install-secrets.sh [env-file]
+#
+# Values are taken from env-file when given, e.g. the ~/btwarch/.env the
+# service used before, and asked for otherwise. A missing JWT_SECRET is
+# generated. Files that already exist are kept; to rotate a secret, write the
+# new value to its file and run `systemctl reload btwarch`.
+set -euo pipefail
+
+SECRETS_DIR=/etc/btwarch
+SERVICE_GROUP=${SERVICE_GROUP:-ubuntu}
+ENV_FILE=${1:-}
+
+if [ "$(id -u)" -ne 0 ]; then
+  echo "install-secrets.sh must run as root" >&2
+  exit 1
+fi
+if [ -n "$ENV_FILE" ] && [ ! -r "$ENV_FILE" ]; then
+  echo "cannot read $ENV_FILE" >&2
+  exit 1
+fi
+
+install -d -m 750 -o root -g "$SERVICE_GROUP" "$SECRETS_DIR"
+
+# from_env_file prints the value of key in ENV_FILE, without quotes.
+from_env_file() {
+  [ -n "$ENV_FILE" ] || return 0
+  sed -n "s/^$1=//p" "$ENV_FILE" | tail -n 1 | sed -e 's/^"\(.*\)"$/\1/' -e "s/^'\(.*\)'$/\1/"
+}
+
+for key in JWT_SECRET CLOUDFLARE_API_TOKEN GITHUB_CLIENT_SECRET; do
+  file="$SECRETS_DIR/$(echo "$key" | tr '[:upper:]' '[:lower:]')"
+  if [ -s "$file" ]; then
+    echo "$file exists, keeping it"
+    continue
+  fi
+
+  value=$(from_env_file "$key")
+  if [ -z "$value" ] && [ "$key" = JWT_SECRET ]; then
+    value=$(openssl rand -hex 32)
+  fi
+  if [ -z "$value" ]; then
+    read -r -s -p "$key: " value
+    echo
+  fi
+  if [ -z "$value" ]; then
+    echo "$key is required" >&2
+    exit 1
+  fi
+
+  (umask 037 && printf '%s\n' "$value" > "$file.tmp")
+  chown root:"$SERVICE_GROUP" "$file.tmp"
+  mv "$file.tmp" "$file"
+  echo "wrote $file"
+done
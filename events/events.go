@@ -0,0 +1,110 @@
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RecordCreated = "record.created"
+	RecordUpdated = "record.updated"
+	RecordDeleted = "record.deleted"
+	ClaimCreated  = "claim.created"
+	ClaimReleased = "claim.released"
+
+	RecordSynced     = "record.synced"
+	RecordSyncFailed = "record.sync_failed"
+)
+
+// Types lists every event type webhooks can subscribe to. RecordSynced and
+// RecordSyncFailed are only streamed to the user's open sessions.
+var Types = []string{RecordCreated, RecordUpdated, RecordDeleted, ClaimCreated, ClaimReleased}
+
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+// SyncResult is the data of record.synced and record.sync_failed events: the
+// outcome of pushing a record change to the DNS provider. RecordID is nil when
+// a failed create left no record behind.
+type SyncResult struct {
+	RecordID   *uuid.UUID `json:"record_id"`
+	RecordName string     `json:"record_name"`
+	RecordType string     `json:"record_type"`
+	Operation  string     `json:"operation"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Event is something that happened to a user's records or claim.
+type Event struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	UserID     uuid.UUID `json:"user_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+type Handler func(Event)
+
+// Bus hands published events to every subscriber, synchronously and in
+// subscription order. Subscribers must not block for long.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *Bus) Publish(eventType string, userID uuid.UUID, data any) {
+	event := Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		UserID:     userID,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		dispatch(handler, event)
+	}
+}
+
+// dispatch keeps a panicking subscriber from failing the request that
+// published the event.
+func dispatch(handler Handler, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Event handler panicked", "event", event.Type, "panic", r)
+		}
+	}()
+	handler(event)
+}
+
+var defaultBus = NewBus()
+
+// Subscribe registers a handler on the process-wide bus.
+func Subscribe(handler Handler) {
+	defaultBus.Subscribe(handler)
+}
+
+// Publish sends an event on the process-wide bus.
+func Publish(eventType string, userID uuid.UUID, data any) {
+	defaultBus.Publish(eventType, userID, data)
+}
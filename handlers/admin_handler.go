@@ -0,0 +1,621 @@
+package handlers
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/events"
+	"btwarch/lifecycle"
+	"btwarch/logging"
+	"btwarch/problem"
+	"btwarch/repositories"
+	"btwarch/services"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type AdminHandler struct {
+	config             *config.Config
+	userRepo           *repositories.UserRepository
+	recordRepo         *repositories.RecordRepository
+	subdomainClaimRepo *repositories.SubdomainClaimRepository
+	reportRepo         *repositories.ReportRepository
+	claimReleaser      *lifecycle.ClaimReleaser
+	userSuspender      *lifecycle.UserSuspender
+	notifier           services.Notifier
+}
+
+func NewAdminHandler(config *config.Config, userRepo *repositories.UserRepository, recordRepo *repositories.RecordRepository, subdomainClaimRepo *repositories.SubdomainClaimRepository, reportRepo *repositories.ReportRepository, claimReleaser *lifecycle.ClaimReleaser, userSuspender *lifecycle.UserSuspender, notifier services.Notifier) *AdminHandler {
+	return &AdminHandler{
+		config:             config,
+		userRepo:           userRepo,
+		recordRepo:         recordRepo,
+		subdomainClaimRepo: subdomainClaimRepo,
+		reportRepo:         reportRepo,
+		claimReleaser:      claimReleaser,
+		userSuspender:      userSuspender,
+		notifier:           notifier,
+	}
+}
+
+func (h *AdminHandler) ListUsers(c *fiber.Ctx) error {
+	page, perPage := parsePagination(c)
+
+	users, total, err := h.userRepo.SearchUsers(c.UserContext(), strings.TrimSpace(c.Query("q")), perPage, (page-1)*perPage)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"users":    users,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
+
+func (h *AdminHandler) GetUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	user, err := h.userRepo.GetUserByID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if user == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeUserNotFound, "user not found")
+	}
+
+	claim, err := h.subdomainClaimRepo.GetClaimByUserID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	records, err := h.recordRepo.GetRecordsByUserID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"user":    user,
+		"claim":   claim,
+		"records": records,
+	})
+}
+
+func (h *AdminHandler) UpdateUserRole(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+	}
+
+	if body.Role != database.RoleUser && body.Role != database.RoleAdmin {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "role must be one of: user, admin")
+	}
+
+	if isCurrentUser(c, userID) && body.Role != database.RoleAdmin {
+		return problem.New(fiber.StatusBadRequest, problem.CodeSelfAction, "admins cannot remove their own admin role")
+	}
+
+	user, err := h.userRepo.GetUserByID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if user == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeUserNotFound, "user not found")
+	}
+
+	if err := h.userRepo.UpdateUserRole(c.UserContext(), userID, body.Role); err != nil {
+		return problem.Internal(err)
+	}
+
+	logging.Audit(c.UserContext(), "Admin set user role", "target_user_id", userID, "role", body.Role)
+
+	user.Role = body.Role
+	return c.JSON(user)
+}
+
+func (h *AdminHandler) SuspendUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+		Until  string `json:"until"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+	}
+
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "reason is required")
+	}
+
+	var until *time.Time
+	if body.Until != "" {
+		t, err := time.Parse(time.RFC3339, body.Until)
+		if err != nil {
+			return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "until must be an RFC 3339 timestamp")
+		}
+		if !t.After(time.Now()) {
+			return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "until must be in the future")
+		}
+		until = &t
+	}
+
+	if isCurrentUser(c, userID) {
+		return problem.New(fiber.StatusBadRequest, problem.CodeSelfAction, "admins cannot suspend themselves")
+	}
+
+	user, err := h.userRepo.GetUserByID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if user == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeUserNotFound, "user not found")
+	}
+
+	if err := h.userSuspender.Suspend(c.UserContext(), userID, body.Reason, until); err != nil {
+		return problem.Internal(err)
+	}
+
+	logging.Audit(c.UserContext(), "Admin suspended user", "target_user_id", userID, "reason", body.Reason, "until", body.Until)
+
+	return h.userResponse(c, userID)
+}
+
+func (h *AdminHandler) UnsuspendUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	var body struct {
+		RestoreRecords bool `json:"restore_records"`
+	}
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+		}
+	}
+
+	user, err := h.userRepo.GetUserByID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if user == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeUserNotFound, "user not found")
+	}
+	if user.SuspendedAt == nil {
+		return problem.New(fiber.StatusConflict, problem.CodeUserNotSuspended, "user is not suspended")
+	}
+
+	if err := h.userSuspender.Unsuspend(c.UserContext(), userID, body.RestoreRecords); err != nil {
+		return problem.Internal(err)
+	}
+
+	logging.Audit(c.UserContext(), "Admin lifted user suspension", "target_user_id", userID, "restore_records", body.RestoreRecords)
+
+	return h.userResponse(c, userID)
+}
+
+func (h *AdminHandler) userResponse(c *fiber.Ctx, userID uuid.UUID) error {
+	user, err := h.userRepo.GetUserByID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	records, err := h.recordRepo.GetRecordsByUserID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"user":    user,
+		"records": records,
+	})
+}
+
+func (h *AdminHandler) ListClaims(c *fiber.Ctx) error {
+	page, perPage := parsePagination(c)
+
+	claims, total, err := h.subdomainClaimRepo.SearchClaims(c.UserContext(), strings.TrimSpace(c.Query("q")), perPage, (page-1)*perPage)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"claims":   claims,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
+
+func (h *AdminHandler) ReleaseClaim(c *fiber.Ctx) error {
+	claimID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid claim id")
+	}
+
+	// Records are deleted unless delete_records is explicitly false, which is
+	// only accepted when there are none: records left behind would stay live
+	// and be inherited by whoever claims the name next.
+	var body struct {
+		DeleteRecords *bool `json:"delete_records"`
+	}
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+		}
+	}
+	deleteRecords := body.DeleteRecords == nil || *body.DeleteRecords
+
+	claim, err := h.subdomainClaimRepo.GetClaimByID(c.UserContext(), claimID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if claim == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeClaimNotFound, "claim not found")
+	}
+
+	if !deleteRecords {
+		records, err := h.recordRepo.GetRecordsBySubdomain(c.UserContext(), claim.SubdomainName+"."+h.config.ParentDomain)
+		if err != nil {
+			return problem.Internal(err)
+		}
+		if len(records) > 0 {
+			return problem.New(fiber.StatusConflict, problem.CodeClaimHasRecords, "the claim still has records; release it with delete_records or delete them first").
+				With("records", len(records))
+		}
+	}
+
+	if err := h.claimReleaser.Release(c.UserContext(), claim, deleteRecords); err != nil {
+		return problem.Internal(err)
+	}
+
+	logging.Audit(c.UserContext(), "Admin force-released claim", "subdomain", claim.SubdomainName, "target_user_id", claim.UserId, "delete_records", deleteRecords)
+
+	h.notify(c.UserContext(), claim.UserId, services.NotificationClaimRevoked,
+		fmt.Sprintf("Your subdomain %s has been released", claim.SubdomainName+"."+h.config.ParentDomain),
+		"An administrator released your claim on the subdomain and deleted its records.",
+	)
+
+	return c.JSON(fiber.Map{
+		"message": "claim released successfully",
+		"claim":   claim,
+	})
+}
+
+// UnlockClaim lifts the lock ActionReport put on a claim and on the records
+// under it. The records stay disabled until their owner enables them.
+func (h *AdminHandler) UnlockClaim(c *fiber.Ctx) error {
+	claimID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid claim id")
+	}
+
+	claim, err := h.subdomainClaimRepo.GetClaimByID(c.UserContext(), claimID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if claim == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeClaimNotFound, "claim not found")
+	}
+
+	if err := h.subdomainClaimRepo.SetModerated(c.UserContext(), claim.ID, false); err != nil {
+		return problem.Internal(err)
+	}
+	if err := h.recordRepo.ClearModerationBySubdomain(c.UserContext(), claim.SubdomainName+"."+h.config.ParentDomain); err != nil {
+		return problem.Internal(err)
+	}
+
+	logging.Audit(c.UserContext(), "Admin unlocked claim", "claim_id", claim.ID, "subdomain", claim.SubdomainName, "target_user_id", claim.UserId)
+
+	updated, err := h.subdomainClaimRepo.GetClaimByID(c.UserContext(), claim.ID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(updated)
+}
+
+func (h *AdminHandler) ListRecords(c *fiber.Ctx) error {
+	page, perPage := parsePagination(c)
+
+	records, total, err := h.recordRepo.SearchRecords(c.UserContext(), strings.TrimSpace(c.Query("q")), perPage, (page-1)*perPage)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"records":  records,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
+
+// DisableRecord takes the record off Cloudflare and locks it, so that its
+// owner cannot enable it again until UnlockRecord is called.
+func (h *AdminHandler) DisableRecord(c *fiber.Ctx) error {
+	recordID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid record id")
+	}
+
+	record, err := h.recordRepo.GetRecordByID(c.UserContext(), recordID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if record == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeRecordNotFound, "record not found")
+	}
+
+	if err := h.recordRepo.ModerateRecord(c.UserContext(), recordID); err != nil {
+		return problem.Internal(err)
+	}
+
+	logging.Audit(c.UserContext(), "Admin disabled record", "record_id", record.ID, "record_type", record.RecordType, "record", record.RecordName, "target_user_id", record.UserId)
+
+	updated, err := h.recordRepo.GetRecordByID(c.UserContext(), recordID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	events.Publish(events.RecordUpdated, updated.UserId, updated)
+	h.notify(c.UserContext(), updated.UserId, services.NotificationRecordDisabled,
+		fmt.Sprintf("Your DNS record %s has been disabled", updated.RecordName),
+		fmt.Sprintf("An administrator disabled your %s record %s. It no longer resolves and cannot be enabled until an administrator lifts the lock.", updated.RecordType, updated.RecordName),
+	)
+
+	return c.JSON(updated)
+}
+
+// UnlockRecord lifts the lock an administrator put on a record.
+// The record stays disabled until its owner enables it.
+func (h *AdminHandler) UnlockRecord(c *fiber.Ctx) error {
+	recordID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid record id")
+	}
+
+	record, err := h.recordRepo.GetRecordByID(c.UserContext(), recordID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if record == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeRecordNotFound, "record not found")
+	}
+
+	if err := h.recordRepo.ClearModeration(c.UserContext(), recordID); err != nil {
+		return problem.Internal(err)
+	}
+
+	logging.Audit(c.UserContext(), "Admin unlocked record", "record_id", record.ID, "record_type", record.RecordType, "record", record.RecordName, "target_user_id", record.UserId)
+
+	updated, err := h.recordRepo.GetRecordByID(c.UserContext(), recordID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	events.Publish(events.RecordUpdated, updated.UserId, updated)
+
+	return c.JSON(updated)
+}
+
+func (h *AdminHandler) ListReports(c *fiber.Ctx) error {
+	page, perPage := parsePagination(c)
+
+	status := c.Query("status", database.ReportStatusOpen)
+	if status == "all" {
+		status = ""
+	}
+
+	reports, total, err := h.reportRepo.ListReports(c.UserContext(), status, strings.ToLower(strings.TrimSpace(c.Query("subdomain"))), perPage, (page-1)*perPage)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"reports":  reports,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
+
+func (h *AdminHandler) GetReport(c *fiber.Ctx) error {
+	report, err := h.getReport(c)
+	if report == nil {
+		return err
+	}
+
+	claim, err := h.subdomainClaimRepo.GetClaimBySubdomain(c.UserContext(), report.SubdomainName)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	records, err := h.recordRepo.GetRecordsBySubdomain(c.UserContext(), report.SubdomainName+"."+h.config.ParentDomain)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"report":  report,
+		"claim":   claim,
+		"records": records,
+	})
+}
+
+func (h *AdminHandler) DismissReport(c *fiber.Ctx) error {
+	report, err := h.getReport(c)
+	if report == nil {
+		return err
+	}
+
+	var body struct {
+		Note string `json:"note"`
+	}
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+		}
+	}
+
+	adminID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	resolved, err := h.reportRepo.ResolveReport(c.UserContext(), report.ID, database.ReportStatusDismissed, adminID, strings.TrimSpace(body.Note))
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if !resolved {
+		return problem.New(fiber.StatusConflict, problem.CodeReportResolved, "report is already resolved")
+	}
+
+	logging.Audit(c.UserContext(), "Admin dismissed report", "report_id", report.ID, "subdomain", report.SubdomainName)
+
+	updated, err := h.reportRepo.GetReportByID(c.UserContext(), report.ID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(updated)
+}
+
+// ActionReport disables every active record under the reported subdomain,
+// locks the claim so that its owner cannot enable them again, and closes all
+// open reports for it as actioned. The claim itself is kept so the name cannot
+// be re-registered straight away.
+func (h *AdminHandler) ActionReport(c *fiber.Ctx) error {
+	report, err := h.getReport(c)
+	if report == nil {
+		return err
+	}
+	if report.Status != database.ReportStatusOpen {
+		return problem.New(fiber.StatusConflict, problem.CodeReportResolved, "report is already resolved")
+	}
+
+	var body struct {
+		Note string `json:"note"`
+	}
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+		}
+	}
+
+	claim, err := h.subdomainClaimRepo.GetClaimBySubdomain(c.UserContext(), report.SubdomainName)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if claim != nil {
+		if err := h.subdomainClaimRepo.SetModerated(c.UserContext(), claim.ID, true); err != nil {
+			return problem.Internal(err)
+		}
+	}
+
+	records, err := h.recordRepo.GetRecordsBySubdomain(c.UserContext(), report.SubdomainName+"."+h.config.ParentDomain)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	disabled := 0
+	disabledByUser := map[uuid.UUID]int{}
+	for _, record := range records {
+		if !record.IsActive {
+			continue
+		}
+		if err := h.recordRepo.ModerateRecord(c.UserContext(), record.ID); err != nil {
+			return problem.Internal(err)
+		}
+		disabled++
+		disabledByUser[record.UserId]++
+
+		record.IsActive = false
+		record.CloudflareRecordID = nil
+		events.Publish(events.RecordUpdated, record.UserId, record)
+	}
+
+	adminID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	resolved, err := h.reportRepo.ResolveOpenReportsBySubdomain(c.UserContext(), report.SubdomainName, database.ReportStatusActioned, adminID, strings.TrimSpace(body.Note))
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	logging.Audit(c.UserContext(), "Admin actioned report", "report_id", report.ID, "subdomain", report.SubdomainName, "disabled_records", disabled)
+
+	domain := report.SubdomainName + "." + h.config.ParentDomain
+	for userID, count := range disabledByUser {
+		h.notify(c.UserContext(), userID, services.NotificationRecordDisabled,
+			fmt.Sprintf("Your DNS records under %s have been disabled", domain),
+			fmt.Sprintf("Following an abuse report, an administrator disabled %d of your records under %s. They no longer resolve, and no record under %s can be enabled until an administrator lifts the lock.", count, domain, domain),
+		)
+	}
+
+	return c.JSON(fiber.Map{
+		"message":          "records disabled",
+		"disabled_records": disabled,
+		"resolved_reports": resolved,
+	})
+}
+
+// getReport loads the report named by the :id parameter. When it returns a nil
+// report the error is the problem to answer with.
+func (h *AdminHandler) getReport(c *fiber.Ctx) (*database.AbuseReport, error) {
+	reportID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid report id")
+	}
+
+	report, err := h.reportRepo.GetReportByID(c.UserContext(), reportID)
+	if err != nil {
+		return nil, problem.Internal(err)
+	}
+	if report == nil {
+		return nil, problem.New(fiber.StatusNotFound, problem.CodeReportNotFound, "report not found")
+	}
+
+	return report, nil
+}
+
+func isCurrentUser(c *fiber.Ctx, userID uuid.UUID) bool {
+	currentUserID, _ := c.Locals("user_id").(string)
+	return currentUserID == userID.String()
+}
+
+func (h *AdminHandler) notify(ctx context.Context, userID uuid.UUID, event, subject, message string) {
+	err := h.notifier.Notify(ctx, services.Notification{
+		UserID:  userID,
+		Event:   event,
+		Subject: subject,
+		Message: message,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending notification", "event", event, "user_id", userID, "error", err)
+	}
+}
@@ -3,21 +3,32 @@ package handlers
 import (
 	"btwarch/config"
 	"btwarch/database"
+	"btwarch/logging"
+	"btwarch/middleware"
+	"btwarch/notifications"
+	"btwarch/problem"
 	"btwarch/repositories"
 	"btwarch/services"
 	"crypto/rand"
 	"encoding/hex"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
-	config         *config.Config
-	githubService  *services.GitHubService
-	authService    *services.AuthService
-	userRepository *repositories.UserRepository
+	config                   *config.Config
+	githubService            *services.GitHubService
+	authService              *services.AuthService
+	userRepository           *repositories.UserRepository
+	subdomainClaimRepository *repositories.SubdomainClaimRepository
+	notificationRepository   *repositories.NotificationRepository
+	notifier                 services.Notifier
 }
 
 func NewAuthHandler(config *config.Config) *AuthHandler {
@@ -25,20 +36,25 @@ func NewAuthHandler(config *config.Config) *AuthHandler {
 		config.GitHubClientID,
 		config.GitHubClientSecret,
 		config.GitHubRedirectURL,
+		config.GitHubTimeout,
 	)
 	authService := services.NewAuthService(
-		config.JWTSecret,
+		services.NewJWTKeys(config),
 		config.CookieDomain,
 		config.CookieSecure,
 		config.CookieSameSite,
 	)
 	userRepository := repositories.NewUserRepository()
+	subdomainClaimRepository := repositories.NewSubdomainClaimRepository()
 
 	return &AuthHandler{
-		config:         config,
-		githubService:  githubService,
-		authService:    authService,
-		userRepository: userRepository,
+		config:                   config,
+		githubService:            githubService,
+		authService:              authService,
+		userRepository:           userRepository,
+		subdomainClaimRepository: subdomainClaimRepository,
+		notificationRepository:   repositories.NewNotificationRepository(),
+		notifier:                 notifications.NewNotifier(config),
 	}
 }
 
@@ -53,38 +69,38 @@ func (h *AuthHandler) InitiateGitHubAuth(c *fiber.Ctx) error {
 func (h *AuthHandler) GitHubCallback(c *fiber.Ctx) error {
 	code := c.Query("code")
 	if code == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"error": "Authorization code is required",
-		})
+		return problem.New(http.StatusBadRequest, problem.CodeValidation, "Authorization code is required")
 	}
 
-	token, err := h.githubService.ExchangeCode(code)
+	token, err := h.githubService.ExchangeCode(c.UserContext(), code)
 	if err != nil {
-		log.Printf("Error exchanging code for token: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to authenticate with GitHub",
-		})
+		slog.ErrorContext(c.UserContext(), "Error exchanging code for token", "error", err)
+		return problem.Internal(err)
 	}
 
-	githubUser, err := h.githubService.GetUserInfo(token)
+	githubUser, err := h.githubService.GetUserInfo(c.UserContext(), token)
 	if err != nil {
-		log.Printf("Error getting user info: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get user information",
-		})
+		slog.ErrorContext(c.UserContext(), "Error getting user info", "error", err)
+		return problem.Internal(err)
 	}
 
-	existingUser, err := h.userRepository.GetUserByGitHubID(githubUser.ID)
+	existingUser, err := h.userRepository.GetUserByGitHubID(c.UserContext(), githubUser.ID)
 	if err != nil {
-		log.Printf("Error checking existing user: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Database error",
-		})
+		slog.ErrorContext(c.UserContext(), "Error checking existing user", "github_id", githubUser.ID, "error", err)
+		return problem.Internal(err)
+	}
+
+	if existingUser != nil && existingUser.Suspended {
+		middleware.AddLogAttrs(c, "user_id", existingUser.ID)
+		slog.WarnContext(c.UserContext(), "Blocked login of suspended user", "username", existingUser.Username)
+		return problem.New(http.StatusForbidden, problem.CodeAccountSuspended, "Account suspended").
+			With("reason", existingUser.SuspensionReason).
+			With("suspended_until", existingUser.SuspendedUntil)
 	}
 
 	var user *database.User
 	if existingUser == nil {
-		user, err = h.userRepository.CreateUser(
+		user, err = h.userRepository.CreateUser(c.UserContext(),
 			githubUser.ID,
 			githubUser.Login,
 			githubUser.Email,
@@ -92,32 +108,42 @@ func (h *AuthHandler) GitHubCallback(c *fiber.Ctx) error {
 			token.AccessToken,
 		)
 		if err != nil {
-			log.Printf("Error creating user: %v", err)
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to create user",
-			})
+			slog.ErrorContext(c.UserContext(), "Error creating user", "github_id", githubUser.ID, "error", err)
+			return problem.Internal(err)
 		}
 	} else {
-		err = h.userRepository.UpdateUserTokens(
+		err = h.userRepository.UpdateUserTokens(c.UserContext(),
 			existingUser.ID.String(),
 			token.AccessToken,
 		)
 		if err != nil {
-			log.Printf("Error updating user tokens: %v", err)
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update user",
-			})
+			slog.ErrorContext(c.UserContext(), "Error updating user tokens", "user_id", existingUser.ID, "error", err)
+			return problem.Internal(err)
 		}
 		user = existingUser
 	}
 
+	middleware.AddLogAttrs(c, "user_id", user.ID)
+
+	if user.Role != database.RoleAdmin && h.isAdminGitHubID(githubUser.ID) {
+		if err := h.userRepository.UpdateUserRole(c.UserContext(), user.ID, database.RoleAdmin); err != nil {
+			slog.ErrorContext(c.UserContext(), "Error promoting user to admin", "error", err)
+		} else {
+			logging.Audit(c.UserContext(), "Promoted user to admin on login", "github_id", githubUser.ID)
+		}
+	}
+
+	if err := h.subdomainClaimRepository.TouchActivityByUserID(c.UserContext(), user.ID); err != nil {
+		slog.ErrorContext(c.UserContext(), "Error updating claim activity", "error", err)
+	}
+
 	if err := h.authService.SetAuthCookie(c, user.ID.String(), user.Username, user.AvatarURL); err != nil {
-		log.Printf("Error setting auth cookie: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to set authentication cookie",
-		})
+		slog.ErrorContext(c.UserContext(), "Error setting auth cookie", "error", err)
+		return problem.Internal(err)
 	}
 
+	h.trackDevice(c, user)
+
 	return c.Redirect("https://dns.btwarch.me/", http.StatusSeeOther)
 	// return c.JSON(fiber.Map{
 	// 	"message": "Authentication successful",
@@ -143,9 +169,18 @@ func (h *AuthHandler) CheckAuth(c *fiber.Ctx) error {
 	username := c.Locals("username")
 	avatar := c.Locals("avatar_url")
 	if userID == nil || username == nil || avatar == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Not authenticated",
-		})
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "Not authenticated")
+	}
+
+	role := database.RoleUser
+	userIDStr, _ := userID.(string)
+	if id, err := uuid.Parse(userIDStr); err == nil {
+		user, err := h.userRepository.GetUserByID(c.UserContext(), id)
+		if err != nil {
+			slog.ErrorContext(c.UserContext(), "Error getting user role", "error", err)
+		} else if user != nil {
+			role = user.Role
+		}
 	}
 
 	return c.JSON(fiber.Map{
@@ -154,10 +189,67 @@ func (h *AuthHandler) CheckAuth(c *fiber.Ctx) error {
 			"id":         userID,
 			"username":   username,
 			"avatar_url": avatar,
+			"role":       role,
 		},
 	})
 }
 
+// trackDevice remembers the browser the user logged in from and tells them
+// when a login comes from a device not seen before. The very first device
+// of a user is not reported.
+func (h *AuthHandler) trackDevice(c *fiber.Ctx, user *database.User) {
+	token := c.Cookies("device_id")
+	if len(token) != 32 {
+		token = generateRandomState()
+	}
+	h.authService.SetDeviceCookie(c, token)
+
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	ip := middleware.ClientIP(c)
+
+	isNew, err := h.notificationRepository.TouchDevice(c.UserContext(), user.ID, hashToken(token), userAgent, ip)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error recording login device", "error", err)
+		return
+	}
+	if !isNew {
+		return
+	}
+
+	devices, err := h.notificationRepository.CountDevices(c.UserContext(), user.ID)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error counting login devices", "error", err)
+		return
+	}
+	if devices < 2 {
+		return
+	}
+
+	if userAgent == "" {
+		userAgent = "unknown browser"
+	}
+	err = h.notifier.Notify(c.UserContext(), services.Notification{
+		UserID:  user.ID,
+		Event:   services.NotificationNewDevice,
+		Subject: "New login to your account",
+		Message: fmt.Sprintf("Your account was signed in to from a new device on %s (%s, IP address %s). If this was not you, revoke the GitHub OAuth app's access and contact us.",
+			time.Now().UTC().Format("2006-01-02 15:04 MST"), userAgent, ip),
+	})
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error sending new device notification", "error", err)
+	}
+}
+
+func (h *AuthHandler) isAdminGitHubID(githubID int64) bool {
+	id := strconv.FormatInt(githubID, 10)
+	for _, adminID := range h.config.AdminGitHubIDs {
+		if adminID == id {
+			return true
+		}
+	}
+	return false
+}
+
 func generateRandomState() string {
 	bytes := make([]byte, 16)
 	rand.Read(bytes)
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"btwarch/config"
+	"btwarch/problem"
+	"btwarch/repositories"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DirectoryHandler struct {
+	config             *config.Config
+	subdomainClaimRepo *repositories.SubdomainClaimRepository
+}
+
+func NewDirectoryHandler(config *config.Config, subdomainClaimRepo *repositories.SubdomainClaimRepository) *DirectoryHandler {
+	return &DirectoryHandler{
+		config:             config,
+		subdomainClaimRepo: subdomainClaimRepo,
+	}
+}
+
+func (h *DirectoryHandler) ListDirectory(c *fiber.Ctx) error {
+	page, perPage := parsePagination(c)
+
+	search := strings.TrimSpace(c.Query("q"))
+	sort := c.Query("sort", "newest")
+
+	entries, total, err := h.subdomainClaimRepo.ListPublicClaims(c.UserContext(), search, sort, perPage, (page-1)*perPage)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	config := h.config
+	for _, entry := range entries {
+		entry.Domain = entry.SubdomainName + "." + config.ParentDomain
+		entry.Link = "https://" + entry.Domain
+	}
+
+	return c.JSON(fiber.Map{
+		"entries":  entries,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
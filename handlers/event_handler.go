@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"btwarch/problem"
+	"btwarch/stream"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type EventHandler struct {
+	broker            *stream.Broker
+	heartbeatInterval time.Duration
+}
+
+func NewEventHandler(broker *stream.Broker, heartbeatInterval time.Duration) *EventHandler {
+	return &EventHandler{
+		broker:            broker,
+		heartbeatInterval: heartbeatInterval,
+	}
+}
+
+// Stream sends the caller's record, claim and sync events as Server-Sent
+// Events until the client disconnects. Comment lines are sent as heartbeats
+// so proxies keep the connection open and dead clients are noticed.
+func (h *EventHandler) Stream(c *fiber.Ctx) error {
+	userIDVal := c.Locals("user_id")
+	userIDStr, ok := userIDVal.(string)
+	if !ok || userIDStr == "" {
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	sub, err := h.broker.Subscribe(userID)
+	if errors.Is(err, stream.ErrTooManyStreams) {
+		return problem.New(fiber.StatusTooManyRequests, problem.CodeTooManyStreams, err.Error())
+	}
+	if errors.Is(err, stream.ErrBrokerClosed) {
+		return problem.New(fiber.StatusServiceUnavailable, problem.CodeShuttingDown, err.Error())
+	}
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	ctx := c.UserContext()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.broker.Unsubscribe(sub)
+
+		heartbeat := time.NewTicker(h.heartbeatInterval)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, "retry: 5000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case event, ok := <-sub.Events:
+				if !ok {
+					return
+				}
+
+				data, err := json.Marshal(event)
+				if err != nil {
+					slog.ErrorContext(ctx, "Error encoding event for stream", "event", event.Type, "error", err)
+					continue
+				}
+				fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
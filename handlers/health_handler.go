@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"btwarch/health"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+	}
+}
+
+// Livez only reports that the process is up and serving requests. It does
+// not look at dependencies, so a database outage does not get the process
+// restarted.
+func (h *HealthHandler) Livez(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": health.StatusOK,
+	})
+}
+
+// Readyz reports whether the instance can serve traffic, with the status of
+// every dependency check. Why a check failed is only logged.
+func (h *HealthHandler) Readyz(c *fiber.Ctx) error {
+	report := h.checker.Report(c.Context())
+
+	status := fiber.StatusOK
+	if !report.Ready() {
+		status = fiber.StatusServiceUnavailable
+	}
+
+	return c.Status(status).JSON(report)
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/notifications"
+	"btwarch/problem"
+	"btwarch/repositories"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+type NotificationHandler struct {
+	config           *config.Config
+	userRepo         *repositories.UserRepository
+	notificationRepo *repositories.NotificationRepository
+	// emailNotifier is nil when email is not configured.
+	emailNotifier *notifications.EmailNotifier
+}
+
+func NewNotificationHandler(config *config.Config, userRepo *repositories.UserRepository, notificationRepo *repositories.NotificationRepository, emailNotifier *notifications.EmailNotifier) *NotificationHandler {
+	return &NotificationHandler{
+		config:           config,
+		userRepo:         userRepo,
+		notificationRepo: notificationRepo,
+		emailNotifier:    emailNotifier,
+	}
+}
+
+func (h *NotificationHandler) GetPreferences(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	prefs, err := h.notificationRepo.GetPreferences(c.UserContext(), user.ID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(h.preferencesResponse(user, prefs))
+}
+
+func (h *NotificationHandler) UpdatePreferences(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	var body struct {
+		Categories map[string]bool `json:"categories"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+	}
+
+	prefs, err := h.notificationRepo.GetPreferences(c.UserContext(), user.ID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	disabled := map[string]bool{}
+	if prefs != nil {
+		for _, category := range prefs.DisabledCategories {
+			disabled[category] = true
+		}
+	}
+
+	for category, enabled := range body.Categories {
+		if !notifications.IsCategory(category) {
+			return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "unknown notification category: "+category)
+		}
+		if !enabled && notifications.IsMandatory(category) {
+			return problem.New(fiber.StatusBadRequest, problem.CodeNotOptional, category+" notifications cannot be turned off")
+		}
+		disabled[category] = !enabled
+	}
+
+	disabledCategories := []string{}
+	for _, category := range notifications.Categories {
+		if disabled[category] {
+			disabledCategories = append(disabledCategories, category)
+		}
+	}
+
+	prefs, err = h.notificationRepo.SetDisabledCategories(c.UserContext(), user.ID, disabledCategories)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(h.preferencesResponse(user, prefs))
+}
+
+// SetEmail starts switching notifications to another address. The address is
+// only used once the link emailed to it has been opened.
+func (h *NotificationHandler) SetEmail(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	if h.emailNotifier == nil {
+		return problem.New(fiber.StatusServiceUnavailable, problem.CodeEmailNotConfigured, "email notifications are not configured")
+	}
+
+	var body struct {
+		Email string `json:"email"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+	}
+
+	email := strings.TrimSpace(body.Email)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || len(email) > 255 {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "invalid email address")
+	}
+
+	if err := h.checkVerificationLimits(c, user.ID, email); err != nil {
+		return err
+	}
+
+	token, err := generateVerificationToken()
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	expiresAt := time.Now().Add(emailVerificationTTL)
+	if err := h.notificationRepo.SetPendingEmail(c.UserContext(), user.ID, email, hashToken(token), expiresAt); err != nil {
+		return problem.Internal(err)
+	}
+
+	if err := h.emailNotifier.SendVerification(c.UserContext(), user, email, token, expiresAt); err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":       "verification email sent",
+		"pending_email": email,
+	})
+}
+
+// checkVerificationLimits keeps SetEmail from being used to flood an inbox:
+// a user waits for the cooldown between verification emails, and neither a
+// user nor a recipient gets more than the configured number while links are
+// valid.
+func (h *NotificationHandler) checkVerificationLimits(c *fiber.Ctx, userID uuid.UUID, email string) error {
+	now := time.Now()
+	stats, err := h.notificationRepo.GetVerificationEmailStats(c.UserContext(), userID, email, now.Add(-emailVerificationTTL))
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	var retryAt time.Time
+	var detail string
+	switch {
+	case stats.UserLastAt != nil && now.Before(stats.UserLastAt.Add(h.config.EmailVerifyCooldown)):
+		retryAt = stats.UserLastAt.Add(h.config.EmailVerifyCooldown)
+		detail = "a verification email was just sent, please wait before requesting another"
+	case stats.UserCount >= h.config.EmailVerifyUserLimit:
+		retryAt = stats.UserFirstAt.Add(emailVerificationTTL)
+		detail = "too many verification emails requested, please try again later"
+	case stats.RecipientCount >= h.config.EmailVerifyRecipientLimit:
+		retryAt = stats.RecipientFirstAt.Add(emailVerificationTTL)
+		detail = "too many verification emails sent to this address, please try again later"
+	default:
+		return nil
+	}
+
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAt.Sub(now).Seconds()))))
+	return problem.New(fiber.StatusTooManyRequests, problem.CodeRateLimited, detail)
+}
+
+func (h *NotificationHandler) DeleteEmail(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if user == nil {
+		return err
+	}
+
+	if err := h.notificationRepo.ClearEmailOverride(c.UserContext(), user.ID); err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "notifications will be sent to your GitHub email address",
+		"email":   user.Email,
+	})
+}
+
+// VerifyEmail confirms an override address. It does not require a session so
+// that the link works from any browser; the token identifies the user.
+func (h *NotificationHandler) VerifyEmail(c *fiber.Ctx) error {
+	token := strings.TrimSpace(c.Query("token"))
+	if token == "" {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "token is required")
+	}
+
+	prefs, err := h.notificationRepo.VerifyPendingEmail(c.UserContext(), hashToken(token))
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if prefs == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeInvalidVerificationLink, "invalid or expired verification link")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "email address verified",
+		"email":   prefs.EmailOverride,
+	})
+}
+
+func (h *NotificationHandler) preferencesResponse(user *database.User, prefs *database.NotificationPreferences) fiber.Map {
+	disabled := map[string]bool{}
+	if prefs != nil {
+		for _, category := range prefs.DisabledCategories {
+			disabled[category] = true
+		}
+	}
+
+	categories := []fiber.Map{}
+	for _, category := range notifications.Categories {
+		categories = append(categories, fiber.Map{
+			"name":      category,
+			"enabled":   notifications.IsMandatory(category) || !disabled[category],
+			"mandatory": notifications.IsMandatory(category),
+		})
+	}
+
+	source := "github"
+	var pendingEmail *string
+	if prefs != nil {
+		if prefs.EmailOverride != nil && prefs.EmailOverrideVerifiedAt != nil {
+			source = "override"
+		}
+		pendingEmail = prefs.PendingEmail
+	}
+
+	return fiber.Map{
+		"email_enabled": h.emailNotifier != nil,
+		"email":         notifications.EmailAddress(user, prefs),
+		"email_source":  source,
+		"pending_email": pendingEmail,
+		"categories":    categories,
+	}
+}
+
+// currentUser loads the authenticated user. When it returns a nil user the
+// error is the problem to answer with.
+func (h *NotificationHandler) currentUser(c *fiber.Ctx) (*database.User, error) {
+	userIDStr, ok := c.Locals("user_id").(string)
+	if !ok || userIDStr == "" {
+		return nil, problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	user, err := h.userRepo.GetUserByID(c.UserContext(), userID)
+	if err != nil {
+		return nil, problem.Internal(err)
+	}
+	if user == nil {
+		return nil, problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	return user, nil
+}
+
+func generateVerificationToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
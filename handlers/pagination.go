@@ -0,0 +1,32 @@
+package handlers
+
+import "github.com/gofiber/fiber/v2"
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// maxPage keeps (page-1)*perPage far from overflowing an int.
+	maxPage = 10000
+)
+
+// parsePagination reads the page and per_page query parameters, clamping them
+// to sane values.
+func parsePagination(c *fiber.Ctx) (page int, perPage int) {
+	page = c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	if page > maxPage {
+		page = maxPage
+	}
+
+	perPage = c.QueryInt("per_page", defaultPageSize)
+	if perPage < 1 {
+		perPage = defaultPageSize
+	}
+	if perPage > maxPageSize {
+		perPage = maxPageSize
+	}
+
+	return page, perPage
+}
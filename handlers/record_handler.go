@@ -3,24 +3,47 @@ package handlers
 import (
 	"btwarch/config"
 	"btwarch/database"
+	"btwarch/events"
+	"btwarch/lifecycle"
+	"btwarch/middleware"
+	"btwarch/policy"
+	"btwarch/problem"
 	"btwarch/repositories"
+	"btwarch/services"
 	"btwarch/utils"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
 type RecordHandler struct {
+	config             *config.Config
 	recordRepo         *repositories.RecordRepository
 	subdomainClaimRepo *repositories.SubdomainClaimRepository
+	waitlistRepo       *repositories.WaitlistRepository
+	reportRepo         *repositories.ReportRepository
+	claimReleaser      *lifecycle.ClaimReleaser
+	targetPolicy       *policy.TargetPolicy
+	notifier           services.Notifier
 }
 
-func NewRecordHandler(recordRepo *repositories.RecordRepository, subdomainClaimRepo *repositories.SubdomainClaimRepository) *RecordHandler {
+func NewRecordHandler(config *config.Config, recordRepo *repositories.RecordRepository, subdomainClaimRepo *repositories.SubdomainClaimRepository, waitlistRepo *repositories.WaitlistRepository, reportRepo *repositories.ReportRepository, claimReleaser *lifecycle.ClaimReleaser, targetPolicy *policy.TargetPolicy, notifier services.Notifier) *RecordHandler {
 	return &RecordHandler{
+		config:             config,
 		recordRepo:         recordRepo,
 		subdomainClaimRepo: subdomainClaimRepo,
+		waitlistRepo:       waitlistRepo,
+		reportRepo:         reportRepo,
+		claimReleaser:      claimReleaser,
+		targetPolicy:       targetPolicy,
+		notifier:           notifier,
 	}
 }
 
@@ -28,12 +51,12 @@ func (h *RecordHandler) ClaimSubdomain(c *fiber.Ctx) error {
 	userIDVal := c.Locals("user_id")
 	userIDStr, ok := userIDVal.(string)
 	if !ok || userIDStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
 	}
 
 	var body struct {
@@ -41,40 +64,109 @@ func (h *RecordHandler) ClaimSubdomain(c *fiber.Ctx) error {
 	}
 
 	if err := c.BodyParser(&body); err != nil || body.SubdomainName == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "subdomain_name is required"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "subdomain_name is required")
 	}
 
-	if err := utils.ValidateSubdomainName(body.SubdomainName); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	subdomainName, err := utils.NormalizeSubdomainName(body.SubdomainName)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
 	}
+	displayName := utils.SubdomainDisplayName(subdomainName)
+	middleware.AddLogAttrs(c, "subdomain", subdomainName)
 
-	existingUserClaim, err := h.subdomainClaimRepo.GetClaimByUserID(userID)
+	existingUserClaim, err := h.subdomainClaimRepo.GetClaimByUserID(c.UserContext(), userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
 	}
 
 	if existingUserClaim != nil {
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "user already has a subdomain claim. Only one subdomain per user is allowed"})
+		if existingUserClaim.Status == database.ClaimStatusCooldown && existingUserClaim.SubdomainName == subdomainName {
+			if err := h.subdomainClaimRepo.ReactivateClaim(c.UserContext(), existingUserClaim.ID); err != nil {
+				return problem.Internal(err)
+			}
+
+			claim, err := h.subdomainClaimRepo.GetClaimByUserID(c.UserContext(), userID)
+			if err != nil {
+				return problem.Internal(err)
+			}
+
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"message":        "subdomain claim reactivated successfully",
+				"claim":          claim,
+				"full_domain":    utils.GetFullSubdomainName(subdomainName, h.config.ParentDomain),
+				"display_domain": utils.GetFullSubdomainName(displayName, h.config.ParentDomain),
+			})
+		}
+
+		return problem.New(fiber.StatusConflict, problem.CodeClaimLimitReached, "user already has a subdomain claim. Only one subdomain per user is allowed")
 	}
 
-	existingClaim, err := h.subdomainClaimRepo.GetClaimBySubdomain(body.SubdomainName)
+	existingClaim, err := h.subdomainClaimRepo.GetClaimBySubdomain(c.UserContext(), subdomainName)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
 	}
 
 	if existingClaim != nil {
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "subdomain already claimed"})
+		return problem.New(fiber.StatusConflict, problem.CodeSubdomainTaken, "subdomain already claimed")
+	}
+
+	skeleton := utils.SubdomainSkeleton(subdomainName)
+	confusableClaim, err := h.subdomainClaimRepo.GetConfusableClaim(c.UserContext(), subdomainName, skeleton)
+	if err != nil {
+		return problem.Internal(err)
 	}
 
-	claim, err := h.subdomainClaimRepo.CreateClaim(userID, body.SubdomainName)
+	if confusableClaim != nil {
+		return problem.New(fiber.StatusConflict, problem.CodeSubdomainTooSimilar, "subdomain name is too similar to an existing claim")
+	}
+
+	reservation, err := h.waitlistRepo.GetActiveReservation(c.UserContext(), subdomainName)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	if reservation != nil && reservation.UserId != userID {
+		return problem.New(fiber.StatusConflict, problem.CodeSubdomainReserved, "subdomain is reserved for another user until "+reservation.ExpiresAt)
+	}
+
+	config := h.config
+	var verifyBy *time.Time
+	if config.ClaimLivenessEnabled {
+		deadline := time.Now().Add(config.ClaimLivenessWindow)
+		verifyBy = &deadline
+	}
+
+	claim, err := h.subdomainClaimRepo.CreateClaim(c.UserContext(), userID, subdomainName, displayName, skeleton, verifyBy)
+	if errors.Is(err, repositories.ErrSubdomainTaken) {
+		return problem.New(fiber.StatusConflict, problem.CodeSubdomainTaken, "subdomain already claimed")
+	}
+	if errors.Is(err, repositories.ErrSubdomainTooSimilar) {
+		return problem.New(fiber.StatusConflict, problem.CodeSubdomainTooSimilar, "subdomain name is too similar to an existing claim")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
 	}
 
+	if reservation != nil {
+		if err := h.waitlistRepo.DeleteReservation(c.UserContext(), subdomainName); err != nil {
+			slog.ErrorContext(c.UserContext(), "Error deleting reservation", "error", err)
+		}
+	}
+	if _, err := h.waitlistRepo.LeaveWaitlist(c.UserContext(), userID, subdomainName); err != nil {
+		slog.ErrorContext(c.UserContext(), "Error removing subdomain from waitlist", "error", err)
+	}
+
+	events.Publish(events.ClaimCreated, userID, claim)
+	h.notify(c.UserContext(), userID, services.NotificationClaimCreated,
+		fmt.Sprintf("You claimed %s", utils.GetFullSubdomainName(subdomainName, h.config.ParentDomain)),
+		"The subdomain is now yours. You can add DNS records for it from the dashboard.",
+	)
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message":     "subdomain claimed successfully",
-		"claim":       claim,
-		"full_domain": utils.GetFullSubdomainName(body.SubdomainName),
+		"message":        "subdomain claimed successfully",
+		"claim":          claim,
+		"full_domain":    utils.GetFullSubdomainName(subdomainName, h.config.ParentDomain),
+		"display_domain": utils.GetFullSubdomainName(displayName, h.config.ParentDomain),
 	})
 }
 
@@ -82,27 +174,35 @@ func (h *RecordHandler) DeleteSubdomain(c *fiber.Ctx) error {
 	userIDVal := c.Locals("user_id")
 	userIDStr, ok := userIDVal.(string)
 	if !ok || userIDStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
 	}
 
-	claim, err := h.subdomainClaimRepo.GetClaimByUserID(userID)
+	claim, err := h.subdomainClaimRepo.GetClaimByUserID(c.UserContext(), userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
 	}
 
 	if claim == nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no subdomain claim found"})
+		return problem.New(fiber.StatusNotFound, problem.CodeClaimNotFound, "no subdomain claim found")
 	}
+	middleware.AddLogAttrs(c, "subdomain", claim.SubdomainName)
 
-	if err := h.subdomainClaimRepo.DeleteClaim(claim.ID); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	// The records go with the claim; left behind they would stay live in DNS
+	// and be inherited by whoever claims the name next.
+	if err := h.claimReleaser.Release(c.UserContext(), claim, true); err != nil {
+		return problem.Internal(err)
 	}
 
+	h.notify(c.UserContext(), userID, services.NotificationClaimReleased,
+		fmt.Sprintf("You released %s", utils.GetFullSubdomainName(claim.SubdomainName, h.config.ParentDomain)),
+		"You deleted your claim on the subdomain and all of its records. It is now available to other users.",
+	)
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "subdomain claim deleted successfully",
 		"claim":   claim,
@@ -113,12 +213,12 @@ func (h *RecordHandler) CreateRecord(c *fiber.Ctx) error {
 	userIDVal := c.Locals("user_id")
 	userIDStr, ok := userIDVal.(string)
 	if !ok || userIDStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
 	}
 
 	var body struct {
@@ -130,54 +230,86 @@ func (h *RecordHandler) CreateRecord(c *fiber.Ctx) error {
 	}
 
 	if err := c.BodyParser(&body); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
 	}
 
 	if body.RecordName == "" || body.RecordType == "" || body.RecordValue == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "record_name, record_type, and record_value are required"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "record_name, record_type, and record_value are required")
+	}
+
+	input := utils.RecordInput{Type: strings.ToUpper(strings.TrimSpace(body.RecordType)), Value: body.RecordValue, TTL: body.TTL}
+	if err := utils.ValidateRecordInput(&input); err != nil {
+		return validationProblem(err)
+	}
+	body.RecordType, body.RecordValue, body.TTL = input.Type, input.Value, input.TTL
+
+	recordName, err := utils.NormalizeRecordName(body.RecordName)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
 	}
+	body.RecordName = recordName
 
-	config := config.LoadConfig()
+	config := h.config
 	if !strings.HasSuffix(body.RecordName, "."+config.ParentDomain) {
 		body.RecordName = body.RecordName + "." + config.ParentDomain
 	}
 
-	subdomainName := utils.ExtractSubdomainFromRecordName(body.RecordName)
+	subdomainName := utils.ExtractSubdomainFromRecordName(body.RecordName, h.config.ParentDomain)
 	if subdomainName == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid record name format"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "invalid record name format")
 	}
+	middleware.AddLogAttrs(c, "subdomain", subdomainName)
 
-	if body.RecordType != "TXT" {
-		claim, err := h.subdomainClaimRepo.GetClaimBySubdomain(subdomainName)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
-		}
+	claim, err := h.subdomainClaimRepo.GetClaimBySubdomain(c.UserContext(), subdomainName)
+	if err != nil {
+		return problem.Internal(err)
+	}
 
-		if claim == nil {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "subdomain not claimed. Please claim the subdomain first"})
-		}
+	if claim == nil {
+		return problem.New(fiber.StatusForbidden, problem.CodeSubdomainUnclaimed, "subdomain not claimed. Please claim the subdomain first")
+	}
 
-		if claim.UserId != userID {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "subdomain claimed by another user"})
-		}
+	if claim.UserId != userID {
+		return problem.New(fiber.StatusForbidden, problem.CodeSubdomainNotOwned, "subdomain claimed by another user")
 	}
 
-	if err := utils.ValidateRecordName(body.RecordName, body.RecordType, subdomainName); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	if claim.Status == database.ClaimStatusCooldown {
+		return problem.New(fiber.StatusForbidden, problem.CodeClaimInCooldown, "subdomain claim is in cooldown due to inactivity. Claim it again to reactivate")
+	}
+
+	if err := utils.ValidateRecordName(h.config, body.RecordName, body.RecordType, subdomainName); err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+	}
+
+	decision, err := h.checkTarget(c.UserContext(), body.RecordName, body.RecordType, body.RecordValue)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if violation := decision.Rejection; violation != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodePolicyViolation, violation.Error()).With("rule", violation.Rule)
 	}
 
-	existingRecord, err := h.recordRepo.GetRecordByNameAndType(body.RecordName, body.RecordType)
+	existingRecord, err := h.recordRepo.GetRecordByNameAndType(c.UserContext(), body.RecordName, body.RecordType)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
+	}
+
+	if existingRecord != nil && existingRecord.UserId != userID {
+		return problem.New(fiber.StatusForbidden, problem.CodeSubdomainNotOwned, "record belongs to another user")
 	}
 
-	if body.RecordType == "TXT" {
-		body.RecordValue = fmt.Sprintf(`"%s"`, body.RecordValue)
+	if body.IsActive {
+		if p := moderationProblem(claim, existingRecord); p != nil {
+			return p
+		}
 	}
 
+	quota := repositories.RecordQuota{MaxRecords: config.RecordQuotaTotal, MaxTXTRecords: config.RecordQuotaTXT}
+	fullSubdomain := utils.GetFullSubdomainName(claim.SubdomainName, h.config.ParentDomain)
+
 	if existingRecord != nil {
-		if err := h.recordRepo.UpdateRecord(existingRecord.ID, body.RecordName, body.RecordType, body.RecordValue, body.TTL); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		if err := h.recordRepo.UpdateRecord(c.UserContext(), claim.ID, fullSubdomain, quota, existingRecord.ID, body.RecordName, body.RecordType, body.RecordValue, body.TTL); err != nil {
+			return problem.Internal(err)
 		}
 
 		if body.IsActive && existingRecord.CloudflareRecordID != nil {
@@ -189,27 +321,45 @@ func (h *RecordHandler) CreateRecord(c *fiber.Ctx) error {
 				TTL:         body.TTL,
 				IsActive:    true,
 			}
-			_, err := h.recordRepo.UpdateOnCloudflare(*existingRecord.CloudflareRecordID, cfRecord)
+			_, err := h.recordRepo.UpdateOnCloudflare(c.UserContext(), *existingRecord.CloudflareRecordID, cfRecord)
+			publishSync(c.UserContext(), userID, &existingRecord.ID, body.RecordName, body.RecordType, events.SyncUpdate, err)
 			if err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": utils.ExtractErrorMessage(err),
-				})
+				return problem.Upstream(err)
 			}
 		}
 
-		updatedRecord, err := h.recordRepo.GetRecordByID(existingRecord.ID)
+		updatedRecord, err := h.recordRepo.GetRecordByID(c.UserContext(), existingRecord.ID)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": utils.ExtractErrorMessage(err),
-			})
+			return problem.Internal(err)
 		}
 
+		h.touchClaimActivity(c.UserContext(), userID)
+		h.reportFlags(c.UserContext(), userID, body.RecordName, body.RecordType, body.RecordValue, decision.Flags)
+		events.Publish(events.RecordUpdated, userID, updatedRecord)
+		h.notifyRecordChanged(c.UserContext(), updatedRecord, "updated")
+
 		return c.Status(fiber.StatusOK).JSON(updatedRecord)
 	}
 
-	record, err := h.recordRepo.CreateRecord(userID, body.RecordName, body.RecordType, body.RecordValue, body.TTL, body.IsActive)
+	record, err := h.recordRepo.CreateRecord(c.UserContext(), claim.ID, fullSubdomain, quota, userID, body.RecordName, body.RecordType, body.RecordValue, body.TTL, body.IsActive)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		if p := quotaProblem(err); p != nil {
+			return p
+		}
+		var syncErr *repositories.CloudflareSyncError
+		if errors.As(err, &syncErr) {
+			publishSync(c.UserContext(), userID, nil, body.RecordName, body.RecordType, events.SyncCreate, syncErr.Err)
+			return problem.Upstream(syncErr.Err)
+		}
+		return problem.Internal(err)
+	}
+
+	h.touchClaimActivity(c.UserContext(), userID)
+	h.reportFlags(c.UserContext(), userID, body.RecordName, body.RecordType, body.RecordValue, decision.Flags)
+	events.Publish(events.RecordCreated, userID, record)
+	h.notifyRecordChanged(c.UserContext(), record, "created")
+	if record.IsActive {
+		publishSync(c.UserContext(), userID, &record.ID, record.RecordName, record.RecordType, events.SyncCreate, nil)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(record)
@@ -219,21 +369,21 @@ func (h *RecordHandler) GetRecords(c *fiber.Ctx) error {
 	userIDVal := c.Locals("user_id")
 	userIDStr, ok := userIDVal.(string)
 	if !ok || userIDStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
 	}
 
-	records, err := h.recordRepo.GetRecordsByUserID(userID)
+	records, err := h.recordRepo.GetRecordsByUserID(c.UserContext(), userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
 	}
 
 	if records == nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no records found"})
+		records = []*database.Record{}
 	}
 
 	return c.JSON(fiber.Map{
@@ -246,25 +396,25 @@ func (h *RecordHandler) GetRecord(c *fiber.Ctx) error {
 	userIDVal := c.Locals("user_id")
 	userIDStr, ok := userIDVal.(string)
 	if !ok || userIDStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
 	}
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
 	}
 
 	idStr := c.Params("id")
 	recordID, err := uuid.Parse(idStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid record id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid record id")
 	}
 
-	record, err := h.recordRepo.GetRecordByID(recordID)
+	record, err := h.recordRepo.GetRecordByID(c.UserContext(), recordID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
 	}
 	if record == nil || record.UserId != userID {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "record not found"})
+		return problem.New(fiber.StatusNotFound, problem.CodeRecordNotFound, "record not found")
 	}
 
 	return c.JSON(record)
@@ -273,26 +423,27 @@ func (h *RecordHandler) UpdateRecord(c *fiber.Ctx) error {
 	userIDVal := c.Locals("user_id")
 	userIDStr, ok := userIDVal.(string)
 	if !ok || userIDStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
 	}
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
 	}
 
 	idStr := c.Params("id")
 	recordID, err := uuid.Parse(idStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid record id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid record id")
 	}
 
-	existing, err := h.recordRepo.GetRecordByID(recordID)
+	existing, err := h.recordRepo.GetRecordByID(c.UserContext(), recordID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
 	}
 	if existing == nil || existing.UserId != userID {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "record not found"})
+		return problem.New(fiber.StatusNotFound, problem.CodeRecordNotFound, "record not found")
 	}
+	middleware.AddLogAttrs(c, "subdomain", utils.ExtractSubdomainFromRecordName(existing.RecordName, h.config.ParentDomain))
 
 	var body struct {
 		RecordName         string `json:"record_name"`
@@ -303,20 +454,53 @@ func (h *RecordHandler) UpdateRecord(c *fiber.Ctx) error {
 		CloudflareRecordID string `json:"cloudflare_record_id"`
 	}
 	if err := c.BodyParser(&body); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
 	}
 	if !body.IsActive {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "is_active is required and must be true	"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "is_active is required and must be true")
 	}
 
-	config := config.LoadConfig()
+	input := utils.RecordInput{Type: strings.ToUpper(strings.TrimSpace(body.RecordType)), Value: body.RecordValue, TTL: body.TTL}
+	if err := utils.ValidateRecordInput(&input); err != nil {
+		return validationProblem(err)
+	}
+	body.RecordType, body.RecordValue, body.TTL = input.Type, input.Value, input.TTL
+
+	recordName, err := utils.NormalizeRecordName(body.RecordName)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+	}
+	body.RecordName = recordName
+
+	config := h.config
 
 	if !strings.HasSuffix(body.RecordName, "."+config.ParentDomain) {
 		body.RecordName = body.RecordName + "." + config.ParentDomain
 	}
 
-	if body.RecordType == "TXT" {
-		body.RecordValue = fmt.Sprintf(`"%s"`, body.RecordValue)
+	subdomainName := utils.ExtractSubdomainFromRecordName(existing.RecordName, h.config.ParentDomain)
+	if err := utils.ValidateRecordName(h.config, body.RecordName, body.RecordType, subdomainName); err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+	}
+
+	claim, err := h.subdomainClaimRepo.GetClaimBySubdomain(c.UserContext(), subdomainName)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if claim == nil || claim.UserId != userID {
+		return problem.New(fiber.StatusForbidden, problem.CodeSubdomainUnclaimed, "the record's subdomain is no longer claimed by you")
+	}
+
+	if p := moderationProblem(claim, existing); p != nil {
+		return p
+	}
+
+	decision, err := h.checkTarget(c.UserContext(), body.RecordName, body.RecordType, body.RecordValue)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if violation := decision.Rejection; violation != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodePolicyViolation, violation.Error()).With("rule", violation.Rule)
 	}
 
 	if body.IsActive {
@@ -329,44 +513,64 @@ func (h *RecordHandler) UpdateRecord(c *fiber.Ctx) error {
 			IsActive:    true,
 		}
 
-		if err := h.recordRepo.UpdateRecord(recordID, body.RecordName, body.RecordType, body.RecordValue, body.TTL); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		quota := repositories.RecordQuota{MaxRecords: config.RecordQuotaTotal, MaxTXTRecords: config.RecordQuotaTXT}
+		fullSubdomain := utils.GetFullSubdomainName(claim.SubdomainName, h.config.ParentDomain)
+		if err := h.recordRepo.UpdateRecord(c.UserContext(), claim.ID, fullSubdomain, quota, recordID, body.RecordName, body.RecordType, body.RecordValue, body.TTL); err != nil {
+			if p := quotaProblem(err); p != nil {
+				return p
+			}
+			return problem.Internal(err)
 		}
 
 		if existing.CloudflareRecordID != nil {
-			if _, err := h.recordRepo.UpdateOnCloudflare(*existing.CloudflareRecordID, cfRecord); err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			if _, err := h.recordRepo.UpdateOnCloudflare(c.UserContext(), *existing.CloudflareRecordID, cfRecord); err != nil {
+				publishSync(c.UserContext(), userID, &recordID, body.RecordName, body.RecordType, events.SyncUpdate, err)
+				return problem.Upstream(err)
 			}
 		} else {
-			newCfID, err := h.recordRepo.CreateCloudflareRecord(cfRecord)
+			newCfID, err := h.recordRepo.CreateCloudflareRecord(c.UserContext(), cfRecord)
 			if err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+				publishSync(c.UserContext(), userID, &recordID, body.RecordName, body.RecordType, events.SyncCreate, err)
+				return problem.Upstream(err)
 			}
 
-			if err := h.recordRepo.UpdateCloudflareIDByNameAndType(body.RecordName, body.RecordType, newCfID.ID); err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			if err := h.recordRepo.UpdateCloudflareIDByNameAndType(c.UserContext(), body.RecordName, body.RecordType, newCfID.ID); err != nil {
+				return problem.Internal(err)
 			}
 		}
 
-		if err := h.recordRepo.UpdateRecordStatus(recordID, true); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		if err := h.recordRepo.UpdateRecordStatus(c.UserContext(), recordID, true); err != nil {
+			return problem.Internal(err)
 		}
 	} else {
 		if existing.CloudflareRecordID != nil {
-			if err := h.recordRepo.DeleteCloudflareRecord(*existing.CloudflareRecordID); err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			if err := h.recordRepo.DeleteCloudflareRecord(c.UserContext(), *existing.CloudflareRecordID); err != nil {
+				publishSync(c.UserContext(), userID, &recordID, existing.RecordName, existing.RecordType, events.SyncDelete, err)
+				return problem.Upstream(err)
 			}
 		}
 
-		if err := h.recordRepo.UpdateRecordStatus(recordID, false); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		if err := h.recordRepo.UpdateRecordStatus(c.UserContext(), recordID, false); err != nil {
+			return problem.Internal(err)
 		}
 	}
 
-	updated, err := h.recordRepo.GetRecordByID(recordID)
+	h.touchClaimActivity(c.UserContext(), userID)
+
+	updated, err := h.recordRepo.GetRecordByID(c.UserContext(), recordID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
+	}
+
+	h.reportFlags(c.UserContext(), userID, body.RecordName, body.RecordType, body.RecordValue, decision.Flags)
+	events.Publish(events.RecordUpdated, userID, updated)
+	h.notifyRecordChanged(c.UserContext(), updated, "updated")
+	if updated.IsActive {
+		publishSync(c.UserContext(), userID, &recordID, updated.RecordName, updated.RecordType, events.SyncUpdate, nil)
+	} else if existing.CloudflareRecordID != nil {
+		publishSync(c.UserContext(), userID, &recordID, existing.RecordName, existing.RecordType, events.SyncDelete, nil)
 	}
+
 	return c.JSON(updated)
 }
 
@@ -376,27 +580,66 @@ func (h *RecordHandler) CheckAvailability(c *fiber.Ctx) error {
 	}
 
 	if err := c.BodyParser(&body); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
 	}
 
 	if body.RecordName == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "record_name is required"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "record_name is required")
+	}
+
+	recordName, err := utils.NormalizeRecordName(body.RecordName)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
 	}
+	body.RecordName = recordName
 
-	config := config.LoadConfig()
+	config := h.config
 	if !strings.HasSuffix(body.RecordName, "."+config.ParentDomain) {
 		body.RecordName = body.RecordName + "." + config.ParentDomain
 	}
 
-	record, err := h.recordRepo.RecordExists(body.RecordName)
+	record, err := h.recordRepo.RecordExists(c.UserContext(), body.RecordName)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
+	}
+
+	userIDStr, _ := c.Locals("user_id").(string)
+
+	claimed := false
+	reserved := false
+	reservedForYou := false
+	waitlistSize := 0
+	if subdomainName := utils.ExtractSubdomainFromRecordName(body.RecordName, h.config.ParentDomain); subdomainName != "" {
+		claim, err := h.subdomainClaimRepo.GetClaimBySubdomain(c.UserContext(), subdomainName)
+		if err != nil {
+			return problem.Internal(err)
+		}
+		claimed = claim != nil
+
+		reservation, err := h.waitlistRepo.GetActiveReservation(c.UserContext(), subdomainName)
+		if err != nil {
+			return problem.Internal(err)
+		}
+		if reservation != nil {
+			reserved = true
+			reservedForYou = reservation.UserId.String() == userIDStr
+		}
+
+		waitlistSize, err = h.waitlistRepo.CountWaitlist(c.UserContext(), subdomainName)
+		if err != nil {
+			return problem.Internal(err)
+		}
 	}
 
-	available := !record
+	available := !record && !claimed && (!reserved || reservedForYou)
 
 	return c.JSON(fiber.Map{
-		"available": available,
+		"available":         available,
+		"claimed":           claimed,
+		"reserved":          reserved,
+		"reserved_for_you":  reservedForYou,
+		"can_join_waitlist": !available && !reservedForYou,
+		"waitlist_size":     waitlistSize,
 	})
 }
 
@@ -404,29 +647,40 @@ func (h *RecordHandler) DeleteRecord(c *fiber.Ctx) error {
 	userIDVal := c.Locals("user_id")
 	userIDStr, ok := userIDVal.(string)
 	if !ok || userIDStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
 	}
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
 	}
 
 	idStr := c.Params("id")
 	recordID, err := uuid.Parse(idStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid record id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid record id")
 	}
 
-	record, err := h.recordRepo.GetRecordByID(recordID)
+	record, err := h.recordRepo.GetRecordByID(c.UserContext(), recordID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
 	}
 	if record == nil || record.UserId != userID {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "record not found"})
+		return problem.New(fiber.StatusNotFound, problem.CodeRecordNotFound, "record not found")
 	}
+	middleware.AddLogAttrs(c, "subdomain", utils.ExtractSubdomainFromRecordName(record.RecordName, h.config.ParentDomain))
 
-	if err := h.recordRepo.DeleteRecord(recordID); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	if err := h.recordRepo.DeleteRecord(c.UserContext(), recordID); err != nil {
+		if record.CloudflareRecordID != nil {
+			publishSync(c.UserContext(), userID, &recordID, record.RecordName, record.RecordType, events.SyncDelete, err)
+		}
+		return problem.Internal(err)
+	}
+
+	h.touchClaimActivity(c.UserContext(), userID)
+	events.Publish(events.RecordDeleted, userID, record)
+	h.notifyRecordChanged(c.UserContext(), record, "deleted")
+	if record.CloudflareRecordID != nil {
+		publishSync(c.UserContext(), userID, &recordID, record.RecordName, record.RecordType, events.SyncDelete, nil)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -439,22 +693,229 @@ func (h *RecordHandler) GetSubdomainClaim(c *fiber.Ctx) error {
 	userIDVal := c.Locals("user_id")
 	userIDStr, ok := userIDVal.(string)
 	if !ok || userIDStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
 	}
 
-	claim, err := h.subdomainClaimRepo.GetClaimByUserID(userID)
+	claim, err := h.subdomainClaimRepo.GetClaimByUserID(c.UserContext(), userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return problem.Internal(err)
 	}
 
 	if claim == nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no subdomain claim found"})
+		return problem.New(fiber.StatusNotFound, problem.CodeClaimNotFound, "no subdomain claim found")
 	}
 
 	return c.JSON(claim)
 }
+
+func (h *RecordHandler) GetRecordQuota(c *fiber.Ctx) error {
+	userIDVal := c.Locals("user_id")
+	userIDStr, ok := userIDVal.(string)
+	if !ok || userIDStr == "" {
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	claim, err := h.subdomainClaimRepo.GetClaimByUserID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	if claim == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeClaimNotFound, "no subdomain claim found")
+	}
+
+	fullSubdomain := utils.GetFullSubdomainName(claim.SubdomainName, h.config.ParentDomain)
+	usage, err := h.recordRepo.GetRecordUsage(c.UserContext(), fullSubdomain)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	config := h.config
+	return c.JSON(fiber.Map{
+		"subdomain": fullSubdomain,
+		"records": fiber.Map{
+			"used":  usage.Records,
+			"limit": config.RecordQuotaTotal,
+		},
+		"txt_records": fiber.Map{
+			"used":  usage.TXTRecords,
+			"limit": config.RecordQuotaTXT,
+		},
+		"max_label_depth": config.RecordMaxLabelDepth,
+	})
+}
+
+// moderationProblem returns the problem for enabling a record that an
+// administrator locked, on its own or through its claim, or nil when neither
+// is locked. record may be nil.
+func moderationProblem(claim *database.SubdomainClaim, record *database.Record) *problem.Problem {
+	if claim.ModeratedAt != nil {
+		return problem.New(fiber.StatusForbidden, problem.CodeRecordModerated, "an administrator disabled the records of this subdomain; they cannot be enabled until the lock is lifted")
+	}
+	if record != nil && record.ModeratedAt != nil {
+		return problem.New(fiber.StatusForbidden, problem.CodeRecordModerated, "an administrator disabled this record; it cannot be enabled until the lock is lifted")
+	}
+	return nil
+}
+
+// quotaProblem returns the problem for a *repositories.QuotaExceededError,
+// or nil for any other error.
+func quotaProblem(err error) *problem.Problem {
+	var quotaErr *repositories.QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		return nil
+	}
+	return problem.New(fiber.StatusForbidden, problem.CodeQuotaExceeded, quotaErr.Error()).
+		With("quota", quotaErr.Quota).
+		With("limit", quotaErr.Limit)
+}
+
+// checkTarget runs a record through the target policy. A rejection in the
+// decision refuses the record; its flags are reported with reportFlags once
+// the change has been saved.
+func (h *RecordHandler) checkTarget(ctx context.Context, recordName, recordType, recordValue string) (*policy.Decision, error) {
+	return h.targetPolicy.Evaluate(ctx, policy.Target{
+		RecordName: recordName,
+		RecordType: recordType,
+		Value:      recordValue,
+	})
+}
+
+// reportFlags files a report for each flag the target policy raised on a
+// record that was created or updated.
+func (h *RecordHandler) reportFlags(ctx context.Context, userID uuid.UUID, recordName, recordType, recordValue string, flags []*policy.Violation) {
+	for _, flag := range flags {
+		slog.WarnContext(ctx, "Target policy flagged record", "rule", flag.Rule, "record", recordName, "record_type", recordType, "value", recordValue, "reason", flag.Reason)
+
+		evidence := fmt.Sprintf("Automatically flagged by the %s target rule: %s record %s -> %s (user %s). %s", flag.Rule, recordType, recordName, recordValue, userID, flag.Reason)
+		if _, err := h.reportRepo.CreateReport(ctx, utils.ExtractSubdomainFromRecordName(recordName, h.config.ParentDomain), "policy", evidence, nil, nil); err != nil {
+			slog.ErrorContext(ctx, "Error filing policy report", "record", recordName, "error", err)
+		}
+	}
+}
+
+// validationProblem describes a failed validation, listing the individual
+// field errors when err is a *utils.ValidationError.
+func validationProblem(err error) *problem.Problem {
+	var verr *utils.ValidationError
+	if errors.As(err, &verr) {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "validation failed").
+			With("fields", verr.Fields)
+	}
+	return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+}
+
+// publishSync reports and logs the outcome of pushing a record change to
+// Cloudflare. err must be the error Cloudflare returned, not a later
+// database failure.
+func publishSync(ctx context.Context, userID uuid.UUID, recordID *uuid.UUID, recordName, recordType, operation string, err error) {
+	result := events.SyncResult{
+		RecordID:   recordID,
+		RecordName: recordName,
+		RecordType: recordType,
+		Operation:  operation,
+	}
+	if err != nil {
+		result.Error = utils.ExtractErrorMessage(err)
+		slog.ErrorContext(ctx, "Cloudflare sync failed", "operation", operation, "record", recordName, "record_type", recordType, "error", err)
+		events.Publish(events.RecordSyncFailed, userID, result)
+		return
+	}
+	slog.InfoContext(ctx, "Cloudflare sync succeeded", "operation", operation, "record", recordName, "record_type", recordType)
+	events.Publish(events.RecordSynced, userID, result)
+}
+
+func (h *RecordHandler) notify(ctx context.Context, userID uuid.UUID, event, subject, message string) {
+	err := h.notifier.Notify(ctx, services.Notification{
+		UserID:  userID,
+		Event:   event,
+		Subject: subject,
+		Message: message,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending notification", "event", event, "user_id", userID, "error", err)
+	}
+}
+
+func (h *RecordHandler) notifyRecordChanged(ctx context.Context, record *database.Record, change string) {
+	message := fmt.Sprintf("Your %s record %s was %s.", record.RecordType, record.RecordName, change)
+	if change != "deleted" {
+		message = fmt.Sprintf("Your %s record %s was %s and now points to %s with a TTL of %d.", record.RecordType, record.RecordName, change, record.RecordValue, record.TTL)
+	}
+
+	h.notify(ctx, record.UserId, services.NotificationRecordChanged,
+		fmt.Sprintf("DNS record %s: %s %s", change, record.RecordType, record.RecordName),
+		message,
+	)
+}
+
+func (h *RecordHandler) touchClaimActivity(ctx context.Context, userID uuid.UUID) {
+	if err := h.subdomainClaimRepo.TouchActivityByUserID(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "Error updating claim activity", "user_id", userID, "error", err)
+	}
+}
+
+func (h *RecordHandler) UpdateSubdomainClaimVisibility(c *fiber.Ctx) error {
+	userIDVal := c.Locals("user_id")
+	userIDStr, ok := userIDVal.(string)
+	if !ok || userIDStr == "" {
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	var body struct {
+		IsPublic    *bool   `json:"is_public"`
+		Description *string `json:"description"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+	}
+
+	claim, err := h.subdomainClaimRepo.GetClaimByUserID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	if claim == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeClaimNotFound, "no subdomain claim found")
+	}
+
+	isPublic := claim.IsPublic
+	if body.IsPublic != nil {
+		isPublic = *body.IsPublic
+	}
+
+	description := claim.Description
+	if body.Description != nil {
+		description = strings.TrimSpace(*body.Description)
+		if utf8.RuneCountInString(description) > 280 {
+			return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "description must be at most 280 characters")
+		}
+	}
+
+	if err := h.subdomainClaimRepo.UpdateClaimVisibility(c.UserContext(), claim.ID, isPublic, description); err != nil {
+		return problem.Internal(err)
+	}
+
+	updated, err := h.subdomainClaimRepo.GetClaimByUserID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(updated)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"btwarch/config"
+	"btwarch/middleware"
+	"btwarch/problem"
+	"btwarch/repositories"
+	"btwarch/utils"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const maxReportEvidenceLength = 5000
+
+var reportCategories = map[string]bool{
+	"phishing": true,
+	"malware":  true,
+	"spam":     true,
+	"illegal":  true,
+	"other":    true,
+}
+
+type ReportHandler struct {
+	config             *config.Config
+	reportRepo         *repositories.ReportRepository
+	subdomainClaimRepo *repositories.SubdomainClaimRepository
+}
+
+func NewReportHandler(config *config.Config, reportRepo *repositories.ReportRepository, subdomainClaimRepo *repositories.SubdomainClaimRepository) *ReportHandler {
+	return &ReportHandler{
+		config:             config,
+		reportRepo:         reportRepo,
+		subdomainClaimRepo: subdomainClaimRepo,
+	}
+}
+
+func (h *ReportHandler) CreateReport(c *fiber.Ctx) error {
+	var body struct {
+		Subdomain     string `json:"subdomain"`
+		Category      string `json:"category"`
+		Evidence      string `json:"evidence"`
+		ReporterEmail string `json:"reporter_email"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+	}
+
+	body.Category = strings.ToLower(strings.TrimSpace(body.Category))
+	if !reportCategories[body.Category] {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "category must be one of: phishing, malware, spam, illegal, other")
+	}
+
+	body.Evidence = strings.TrimSpace(body.Evidence)
+	if body.Evidence == "" {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "evidence is required")
+	}
+	if utf8.RuneCountInString(body.Evidence) > maxReportEvidenceLength {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "evidence must be at most 5000 characters")
+	}
+
+	var reporterEmail *string
+	if email := strings.TrimSpace(body.ReporterEmail); email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "reporter_email is not a valid email address")
+		}
+		reporterEmail = &email
+	}
+
+	subdomainName, err := reportedSubdomain(body.Subdomain, h.config.ParentDomain)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+	}
+
+	claim, err := h.subdomainClaimRepo.GetClaimBySubdomain(c.UserContext(), subdomainName)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if claim == nil {
+		return problem.New(fiber.StatusNotFound, problem.CodeSubdomainUnclaimed, "subdomain is not claimed")
+	}
+
+	reporterIP := middleware.ClientIP(c)
+	report, err := h.reportRepo.CreateReport(c.UserContext(), subdomainName, body.Category, body.Evidence, reporterEmail, &reporterIP)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "report received",
+		"id":      report.ID,
+	})
+}
+
+// reportedSubdomain resolves what a reporter typed, which may be a bare name,
+// a hostname under the parent domain or a full URL, to the claimed subdomain
+// label.
+func reportedSubdomain(input, parentDomain string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("subdomain is required")
+	}
+
+	if i := strings.Index(input, "://"); i >= 0 {
+		input = input[i+3:]
+	}
+	if i := strings.IndexAny(input, "/?#"); i >= 0 {
+		input = input[:i]
+	}
+	if i := strings.LastIndex(input, ":"); i >= 0 {
+		input = input[:i]
+	}
+
+	hostname, err := utils.NormalizeRecordName(strings.TrimSuffix(input, "."))
+	if err != nil {
+		return "", err
+	}
+
+	if hostname == parentDomain {
+		return "", fmt.Errorf("subdomain is required")
+	}
+	if strings.HasSuffix(hostname, "."+parentDomain) {
+		hostname = strings.TrimSuffix(hostname, "."+parentDomain)
+	} else if strings.Contains(hostname, ".") {
+		return "", fmt.Errorf("subdomain must be under %s", parentDomain)
+	}
+
+	labels := strings.Split(hostname, ".")
+	return utils.NormalizeSubdomainName(labels[len(labels)-1])
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"btwarch/problem"
+	"btwarch/repositories"
+	"btwarch/utils"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type WaitlistHandler struct {
+	waitlistRepo       *repositories.WaitlistRepository
+	subdomainClaimRepo *repositories.SubdomainClaimRepository
+}
+
+func NewWaitlistHandler(waitlistRepo *repositories.WaitlistRepository, subdomainClaimRepo *repositories.SubdomainClaimRepository) *WaitlistHandler {
+	return &WaitlistHandler{
+		waitlistRepo:       waitlistRepo,
+		subdomainClaimRepo: subdomainClaimRepo,
+	}
+}
+
+func (h *WaitlistHandler) JoinWaitlist(c *fiber.Ctx) error {
+	userIDVal := c.Locals("user_id")
+	userIDStr, ok := userIDVal.(string)
+	if !ok || userIDStr == "" {
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	var body struct {
+		SubdomainName string `json:"subdomain_name"`
+	}
+
+	if err := c.BodyParser(&body); err != nil || body.SubdomainName == "" {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "subdomain_name is required")
+	}
+
+	subdomainName, err := utils.NormalizeSubdomainName(body.SubdomainName)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+	}
+
+	claim, err := h.subdomainClaimRepo.GetClaimBySubdomain(c.UserContext(), subdomainName)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	reservation, err := h.waitlistRepo.GetActiveReservation(c.UserContext(), subdomainName)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	if claim == nil && reservation == nil {
+		return problem.New(fiber.StatusConflict, problem.CodeSubdomainAvailable, "subdomain is available. Claim it instead of joining the waitlist")
+	}
+
+	if claim != nil && claim.UserId == userID {
+		return problem.New(fiber.StatusConflict, problem.CodeSubdomainOwned, "you already own this subdomain")
+	}
+
+	if reservation != nil && reservation.UserId == userID {
+		return problem.New(fiber.StatusConflict, problem.CodeSubdomainReservedForYou, "subdomain is reserved for you until "+reservation.ExpiresAt+". Claim it now")
+	}
+
+	entry, err := h.waitlistRepo.JoinWaitlist(c.UserContext(), userID, subdomainName)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "joined waitlist successfully",
+		"entry":   entry,
+	})
+}
+
+func (h *WaitlistHandler) GetWaitlist(c *fiber.Ctx) error {
+	userIDVal := c.Locals("user_id")
+	userIDStr, ok := userIDVal.(string)
+	if !ok || userIDStr == "" {
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	entries, err := h.waitlistRepo.GetWaitlistByUserID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	reservations, err := h.waitlistRepo.GetReservationsByUserID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"waitlist":     entries,
+		"reservations": reservations,
+	})
+}
+
+func (h *WaitlistHandler) LeaveWaitlist(c *fiber.Ctx) error {
+	userIDVal := c.Locals("user_id")
+	userIDStr, ok := userIDVal.(string)
+	if !ok || userIDStr == "" {
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	name, err := url.PathUnescape(c.Params("name"))
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, "invalid subdomain name")
+	}
+
+	subdomainName, err := utils.NormalizeSubdomainName(name)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+	}
+
+	removed, err := h.waitlistRepo.LeaveWaitlist(c.UserContext(), userID, subdomainName)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	if !removed {
+		return problem.New(fiber.StatusNotFound, problem.CodeWaitlistEntryNotFound, "not on the waitlist for this subdomain")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "left waitlist successfully",
+	})
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/events"
+	"btwarch/problem"
+	"btwarch/repositories"
+	"btwarch/webhooks"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const maxWebhooksPerUser = 5
+
+type WebhookHandler struct {
+	config      *config.Config
+	webhookRepo *repositories.WebhookRepository
+}
+
+func NewWebhookHandler(config *config.Config, webhookRepo *repositories.WebhookRepository) *WebhookHandler {
+	return &WebhookHandler{
+		config:      config,
+		webhookRepo: webhookRepo,
+	}
+}
+
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	userIDVal := c.Locals("user_id")
+	userIDStr, ok := userIDVal.(string)
+	if !ok || userIDStr == "" {
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	var body struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+	}
+
+	webhookURL, err := validateWebhookURL(body.URL, h.config.WebhookAllowPrivateTargets)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+	}
+
+	eventTypes, err := validateWebhookEvents(body.Events)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+	}
+
+	count, err := h.webhookRepo.CountWebhooksByUserID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if count >= maxWebhooksPerUser {
+		return problem.New(fiber.StatusConflict, problem.CodeWebhookLimitReached, fmt.Sprintf("a user can have at most %d webhooks", maxWebhooksPerUser))
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	webhook, err := h.webhookRepo.CreateWebhook(c.UserContext(), userID, webhookURL, secret, eventTypes)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	// The secret is only ever returned here.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"webhook": webhook,
+		"secret":  secret,
+	})
+}
+
+func (h *WebhookHandler) GetWebhooks(c *fiber.Ctx) error {
+	userIDVal := c.Locals("user_id")
+	userIDStr, ok := userIDVal.(string)
+	if !ok || userIDStr == "" {
+		return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	webhooks, err := h.webhookRepo.GetWebhooksByUserID(c.UserContext(), userID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"webhooks":         webhooks,
+		"available_events": events.Types,
+	})
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	webhook, err := h.getOwnedWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	var body struct {
+		URL      *string  `json:"url"`
+		Events   []string `json:"events"`
+		IsActive *bool    `json:"is_active"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidBody, "invalid request body")
+	}
+
+	if body.URL != nil {
+		webhookURL, err := validateWebhookURL(*body.URL, h.config.WebhookAllowPrivateTargets)
+		if err != nil {
+			return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+		}
+		webhook.URL = webhookURL
+	}
+	if body.Events != nil {
+		eventTypes, err := validateWebhookEvents(body.Events)
+		if err != nil {
+			return problem.New(fiber.StatusBadRequest, problem.CodeValidation, err.Error())
+		}
+		webhook.Events = eventTypes
+	}
+	if body.IsActive != nil {
+		webhook.IsActive = *body.IsActive
+	}
+
+	if err := h.webhookRepo.UpdateWebhook(c.UserContext(), webhook.ID, webhook.URL, webhook.Events, webhook.IsActive); err != nil {
+		return problem.Internal(err)
+	}
+
+	updated, err := h.webhookRepo.GetWebhookByID(c.UserContext(), webhook.ID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(updated)
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	webhook, err := h.getOwnedWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	if err := h.webhookRepo.DeleteWebhook(c.UserContext(), webhook.ID); err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "webhook deleted successfully",
+		"webhook": webhook,
+	})
+}
+
+func (h *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	webhook, err := h.getOwnedWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	page, perPage := parsePagination(c)
+
+	deliveries, total, err := h.webhookRepo.ListDeliveries(c.UserContext(), webhook.ID, perPage, (page-1)*perPage)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"deliveries": deliveries,
+		"page":       page,
+		"per_page":   perPage,
+		"total":      total,
+	})
+}
+
+// Redeliver queues a fresh delivery of an earlier delivery's payload. The
+// original delivery is kept in the log as it was.
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	webhook, err := h.getOwnedWebhook(c)
+	if webhook == nil {
+		return err
+	}
+
+	deliveryID, err := uuid.Parse(c.Params("deliveryId"))
+	if err != nil {
+		return problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid delivery id")
+	}
+
+	delivery, err := h.webhookRepo.GetDeliveryByID(c.UserContext(), deliveryID)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	if delivery == nil || delivery.WebhookID != webhook.ID {
+		return problem.New(fiber.StatusNotFound, problem.CodeDeliveryNotFound, "delivery not found")
+	}
+
+	redelivery, err := h.webhookRepo.CreateDelivery(c.UserContext(), webhook.ID, delivery.EventID, delivery.Event, delivery.Payload)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(redelivery)
+}
+
+// getOwnedWebhook loads the webhook named by the :id parameter if it belongs
+// to the current user. When it returns a nil webhook the error is the problem
+// to answer with.
+func (h *WebhookHandler) getOwnedWebhook(c *fiber.Ctx) (*database.Webhook, error) {
+	userIDStr, ok := c.Locals("user_id").(string)
+	if !ok || userIDStr == "" {
+		return nil, problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "unauthorized")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid user id")
+	}
+
+	webhookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, problem.New(fiber.StatusBadRequest, problem.CodeInvalidID, "invalid webhook id")
+	}
+
+	webhook, err := h.webhookRepo.GetWebhookByID(c.UserContext(), webhookID)
+	if err != nil {
+		return nil, problem.Internal(err)
+	}
+	if webhook == nil || webhook.UserId != userID {
+		return nil, problem.New(fiber.StatusNotFound, problem.CodeWebhookNotFound, "webhook not found")
+	}
+
+	return webhook, nil
+}
+
+func validateWebhookURL(rawURL string, allowPrivateTargets bool) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", fmt.Errorf("url is required")
+	}
+	if len(rawURL) > 2048 {
+		return "", fmt.Errorf("url must be at most 2048 characters")
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("url must be an absolute URL")
+	}
+
+	switch parsed.Scheme {
+	case "https":
+	case "http":
+		if !allowPrivateTargets {
+			return "", fmt.Errorf("url must use https")
+		}
+	default:
+		return "", fmt.Errorf("url must use https")
+	}
+
+	if parsed.User != nil {
+		return "", fmt.Errorf("url must not contain credentials")
+	}
+
+	host := parsed.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if addr.Zone() != "" {
+			return "", fmt.Errorf("url must not contain an IPv6 zone")
+		}
+		if !allowPrivateTargets {
+			if err := webhooks.CheckTargetAddress(host); err != nil {
+				return "", err
+			}
+		}
+	} else if !allowPrivateTargets && (host == "localhost" || strings.HasSuffix(host, ".localhost")) {
+		return "", fmt.Errorf("webhook target %s is not allowed", host)
+	}
+
+	return parsed.String(), nil
+}
+
+// validateWebhookEvents checks the requested event types. No event types means
+// all of them.
+func validateWebhookEvents(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return events.Types, nil
+	}
+
+	known := map[string]bool{}
+	for _, eventType := range events.Types {
+		known[eventType] = true
+	}
+
+	seen := map[string]bool{}
+	var eventTypes []string
+	for _, eventType := range requested {
+		eventType = strings.TrimSpace(eventType)
+		if !known[eventType] {
+			return nil, fmt.Errorf("unknown event %q, must be one of: %s", eventType, strings.Join(events.Types, ", "))
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+
+	return eventTypes, nil
+}
+
+func generateWebhookSecret() (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("error generating webhook secret: %v", err)
+	}
+	return "whsec_" + hex.EncodeToString(bytes), nil
+}
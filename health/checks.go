@@ -0,0 +1,85 @@
+package health
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/services"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DatabaseCheck pings Postgres.
+func DatabaseCheck(db *sql.DB) Check {
+	return Check{
+		Name:     "database",
+		Critical: true,
+		Run: func(ctx context.Context) error {
+			return db.PingContext(ctx)
+		},
+	}
+}
+
+// MigrationsCheck fails while migration files on disk have not been applied.
+func MigrationsCheck(db *sql.DB, migrationsDir string) Check {
+	return Check{
+		Name:     "migrations",
+		Critical: true,
+		Run: func(ctx context.Context) error {
+			applied, err := database.GetAppliedMigrations(db)
+			if err != nil {
+				return fmt.Errorf("error getting applied migrations: %v", err)
+			}
+
+			files, err := database.ReadMigrationFiles(migrationsDir)
+			if err != nil {
+				return fmt.Errorf("error reading migration files: %v", err)
+			}
+
+			var pending []string
+			for _, file := range files {
+				version := database.ExtractVersion(file)
+				if _, ok := applied[version]; !ok {
+					pending = append(pending, version)
+				}
+			}
+
+			if len(pending) > 0 {
+				sort.Strings(pending)
+				return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// CloudflareCheck checks that the API is reachable and the token can still
+// read the zone. The client is reused until the token is rotated.
+func CloudflareCheck(cfg *config.Config) Check {
+	var mu sync.Mutex
+	var client *services.CloudflareService
+	var clientToken string
+
+	return Check{
+		Name:     "cloudflare",
+		Critical: cfg.HealthProviderCritical,
+		Run: func(ctx context.Context) error {
+			mu.Lock()
+			if token := cfg.CloudFlareApiToken.Value(); client == nil || token != clientToken {
+				cf, err := services.NewCloudflareService(token, cfg.CloudFlareZoneId, cfg.CloudflareTimeout)
+				if err != nil {
+					mu.Unlock()
+					return err
+				}
+				client, clientToken = cf, token
+			}
+			cf := client
+			mu.Unlock()
+
+			return cf.Ping(ctx)
+		},
+	}
+}
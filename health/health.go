@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFail     = "fail"
+)
+
+// Check is a single dependency check. A failing critical check makes the
+// instance unready; a failing non-critical check only degrades it.
+type Check struct {
+	Name     string
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of one check. The error is only logged; the
+// report is public and errors can name hosts, users or token problems.
+type CheckResult struct {
+	Status     string `json:"status"`
+	Critical   bool   `json:"critical"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"-"`
+}
+
+type Report struct {
+	Status    string                 `json:"status"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Checks    map[string]CheckResult `json:"checks"`
+}
+
+func (r *Report) Ready() bool {
+	return r.Status != StatusFail
+}
+
+// Checker runs its checks concurrently, each with its own timeout, and
+// caches the report so that frequent probes do not hammer the dependencies.
+type Checker struct {
+	checks   []Check
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu     sync.Mutex
+	report *Report
+	// running is closed when the run in progress, if any, finishes.
+	running chan struct{}
+}
+
+func NewChecker(timeout, cacheTTL time.Duration, checks ...Check) *Checker {
+	return &Checker{
+		checks:   checks,
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// Report returns the cached report, running the checks again once it is
+// older than the cache TTL. Concurrent callers wait for a single run, which
+// happens outside the lock and is not cut short when the caller that started
+// it goes away.
+func (c *Checker) Report(ctx context.Context) *Report {
+	c.mu.Lock()
+	if c.report != nil && time.Since(c.report.CheckedAt) < c.cacheTTL {
+		report := c.report
+		c.mu.Unlock()
+		return report
+	}
+	if running := c.running; running != nil {
+		c.mu.Unlock()
+		<-running
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.report
+	}
+	running := make(chan struct{})
+	c.running = running
+	c.mu.Unlock()
+
+	report := c.runChecks(context.WithoutCancel(ctx))
+
+	c.mu.Lock()
+	c.report, c.running = report, nil
+	c.mu.Unlock()
+	close(running)
+	return report
+}
+
+func (c *Checker) runChecks(ctx context.Context) *Report {
+	report := &Report{
+		Status:    StatusOK,
+		CheckedAt: time.Now().UTC(),
+		Checks:    make(map[string]CheckResult, len(c.checks)),
+	}
+
+	results := make([]CheckResult, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for i, check := range c.checks {
+		result := results[i]
+		report.Checks[check.Name] = result
+		if result.Status == StatusOK {
+			continue
+		}
+		slog.WarnContext(ctx, "Health check failed", "check", check.Name, "critical", check.Critical, "error", result.Error)
+		if check.Critical {
+			report.Status = StatusFail
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+// run gives up on a check once its timeout passes, even if the check itself
+// ignores the context.
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked: %v", r)
+			}
+		}()
+		done <- check.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+
+	result := CheckResult{
+		Status:     StatusOK,
+		Critical:   check.Critical,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package lifecycle
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/repositories"
+	"btwarch/services"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ClaimExpiryJob moves inactive claims through the expiry lifecycle:
+// active -> warned -> cooldown -> released. Owner activity while a claim is
+// warned puts it back to active.
+type ClaimExpiryJob struct {
+	config    *config.Config
+	claimRepo *repositories.SubdomainClaimRepository
+	releaser  *ClaimReleaser
+	notifier  services.Notifier
+}
+
+func NewClaimExpiryJob(config *config.Config, claimRepo *repositories.SubdomainClaimRepository, releaser *ClaimReleaser, notifier services.Notifier) *ClaimExpiryJob {
+	return &ClaimExpiryJob{
+		config:    config,
+		claimRepo: claimRepo,
+		releaser:  releaser,
+		notifier:  notifier,
+	}
+}
+
+func (j *ClaimExpiryJob) Name() string {
+	return "claim-expiry"
+}
+
+func (j *ClaimExpiryJob) Run(ctx context.Context) error {
+	if err := j.warnInactiveClaims(ctx); err != nil {
+		return err
+	}
+	if err := j.startCooldowns(ctx); err != nil {
+		return err
+	}
+	return j.releaseCooledDownClaims(ctx)
+}
+
+func (j *ClaimExpiryJob) warnInactiveClaims(ctx context.Context) error {
+	claims, err := j.claimRepo.GetInactiveClaims(ctx, j.config.ClaimInactivityDays, j.config.ParentDomain)
+	if err != nil {
+		return fmt.Errorf("error getting inactive claims: %w", err)
+	}
+
+	releaseAt := time.Now().AddDate(0, 0, j.config.ClaimGraceDays+j.config.ClaimCooldownDays)
+	for _, claim := range claims {
+		if err := j.claimRepo.UpdateClaimStatus(ctx, claim.ID, database.ClaimStatusWarned); err != nil {
+			slog.ErrorContext(ctx, "Error warning claim", "subdomain", claim.SubdomainName, "error", err)
+			continue
+		}
+
+		j.notify(ctx, claim, services.NotificationClaimInactive,
+			fmt.Sprintf("Your subdomain %s.%s is inactive", claim.SubdomainName, j.config.ParentDomain),
+			fmt.Sprintf("Your subdomain has had no records and no activity for %d days. Log in or add a record within %d days to keep it. Otherwise it will be released on %s.",
+				j.config.ClaimInactivityDays, j.config.ClaimGraceDays, releaseAt.Format("2006-01-02")),
+		)
+	}
+
+	return nil
+}
+
+func (j *ClaimExpiryJob) startCooldowns(ctx context.Context) error {
+	claims, err := j.claimRepo.GetClaimsInStatusFor(ctx, database.ClaimStatusWarned, j.config.ClaimGraceDays)
+	if err != nil {
+		return fmt.Errorf("error getting warned claims: %w", err)
+	}
+
+	releaseAt := time.Now().AddDate(0, 0, j.config.ClaimCooldownDays)
+	for _, claim := range claims {
+		if err := j.claimRepo.UpdateClaimStatus(ctx, claim.ID, database.ClaimStatusCooldown); err != nil {
+			slog.ErrorContext(ctx, "Error moving claim to cooldown", "subdomain", claim.SubdomainName, "error", err)
+			continue
+		}
+
+		j.notify(ctx, claim, services.NotificationClaimCooldown,
+			fmt.Sprintf("Your subdomain %s.%s has been deactivated", claim.SubdomainName, j.config.ParentDomain),
+			fmt.Sprintf("The grace period has ended. Claim the subdomain again before %s to keep it, after which the name will be released.",
+				releaseAt.Format("2006-01-02")),
+		)
+	}
+
+	return nil
+}
+
+func (j *ClaimExpiryJob) releaseCooledDownClaims(ctx context.Context) error {
+	claims, err := j.claimRepo.GetClaimsInStatusFor(ctx, database.ClaimStatusCooldown, j.config.ClaimCooldownDays)
+	if err != nil {
+		return fmt.Errorf("error getting claims in cooldown: %w", err)
+	}
+
+	for _, claim := range claims {
+		if err := j.releaser.Release(ctx, claim, true); err != nil {
+			slog.ErrorContext(ctx, "Error releasing claim", "subdomain", claim.SubdomainName, "error", err)
+			continue
+		}
+
+		j.notify(ctx, claim, services.NotificationClaimReleased,
+			fmt.Sprintf("Your subdomain %s.%s has been released", claim.SubdomainName, j.config.ParentDomain),
+			"The subdomain was inactive and has been released together with its records. It is now available to other users.",
+		)
+	}
+
+	return nil
+}
+
+func (j *ClaimExpiryJob) notify(ctx context.Context, claim *database.SubdomainClaim, event, subject, message string) {
+	err := j.notifier.Notify(ctx, services.Notification{
+		UserID:  claim.UserId,
+		Event:   event,
+		Subject: subject,
+		Message: message,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending notification", "event", event, "subdomain", claim.SubdomainName, "error", err)
+	}
+}
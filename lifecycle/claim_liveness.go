@@ -0,0 +1,132 @@
+package lifecycle
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/policy"
+	"btwarch/repositories"
+	"btwarch/services"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ClaimLivenessJob probes unverified claims and releases those that did not
+// get a responding A, AAAA or CNAME record before their deadline.
+type ClaimLivenessJob struct {
+	config     *config.Config
+	claimRepo  *repositories.SubdomainClaimRepository
+	recordRepo *repositories.RecordRepository
+	releaser   *ClaimReleaser
+	prober     *services.LivenessProber
+	notifier   services.Notifier
+}
+
+func NewClaimLivenessJob(config *config.Config, claimRepo *repositories.SubdomainClaimRepository, recordRepo *repositories.RecordRepository, releaser *ClaimReleaser, prober *services.LivenessProber, notifier services.Notifier) *ClaimLivenessJob {
+	return &ClaimLivenessJob{
+		config:     config,
+		claimRepo:  claimRepo,
+		recordRepo: recordRepo,
+		releaser:   releaser,
+		prober:     prober,
+		notifier:   notifier,
+	}
+}
+
+// CheckLivenessTarget rejects the loopback, private, link-local and reserved
+// addresses the prober must not connect to, whether a record names them
+// directly or a CNAME resolves to them.
+func CheckLivenessTarget(ip string) error {
+	if violation := policy.CheckPublicAddress(ip); violation != nil {
+		return fmt.Errorf("liveness target %s is not allowed: %s", ip, violation.Reason)
+	}
+	return nil
+}
+
+func (j *ClaimLivenessJob) Name() string {
+	return "claim-liveness"
+}
+
+func (j *ClaimLivenessJob) Run(ctx context.Context) error {
+	claims, err := j.claimRepo.GetUnverifiedClaims(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting unverified claims: %w", err)
+	}
+
+	for _, claim := range claims {
+		j.verify(ctx, claim)
+	}
+
+	expired, err := j.claimRepo.GetUnverifiedClaimsPastDeadline(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting expired unverified claims: %w", err)
+	}
+
+	for _, claim := range expired {
+		if err := j.release(ctx, claim); err != nil {
+			slog.ErrorContext(ctx, "Error releasing unverified claim", "subdomain", claim.SubdomainName, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (j *ClaimLivenessJob) verify(ctx context.Context, claim *database.SubdomainClaim) {
+	hostname := claim.SubdomainName + "." + j.config.ParentDomain
+
+	records, err := j.recordRepo.GetRecordsBySubdomain(ctx, hostname)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting records for claim", "subdomain", claim.SubdomainName, "error", err)
+		return
+	}
+
+	var failures []string
+	for _, record := range records {
+		if record.RecordName != hostname || !record.IsActive {
+			continue
+		}
+		if record.RecordType != "A" && record.RecordType != "AAAA" && record.RecordType != "CNAME" {
+			continue
+		}
+
+		if err := j.prober.Probe(ctx, hostname, record); err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+
+		if err := j.claimRepo.MarkClaimVerified(ctx, claim.ID); err != nil {
+			slog.ErrorContext(ctx, "Error marking claim verified", "subdomain", claim.SubdomainName, "error", err)
+		}
+		return
+	}
+
+	reason := "no active A, AAAA or CNAME record"
+	if len(failures) > 0 {
+		reason = strings.Join(failures, "; ")
+	}
+
+	if err := j.claimRepo.RecordVerificationFailure(ctx, claim.ID, reason); err != nil {
+		slog.ErrorContext(ctx, "Error recording verification failure", "subdomain", claim.SubdomainName, "error", err)
+	}
+}
+
+func (j *ClaimLivenessJob) release(ctx context.Context, claim *database.SubdomainClaim) error {
+	hostname := claim.SubdomainName + "." + j.config.ParentDomain
+
+	if err := j.releaser.Release(ctx, claim, true); err != nil {
+		return err
+	}
+
+	err := j.notifier.Notify(ctx, services.Notification{
+		UserID:  claim.UserId,
+		Event:   services.NotificationClaimReleased,
+		Subject: fmt.Sprintf("Your subdomain %s has been released", hostname),
+		Message: fmt.Sprintf("No A, AAAA or CNAME record for the subdomain responded over HTTP(S) within %s of claiming it, so the claim and its records were removed.", j.config.ClaimLivenessWindow),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending release notification", "subdomain", claim.SubdomainName, "error", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package lifecycle
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/events"
+	"btwarch/repositories"
+	"btwarch/services"
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// ClaimReleaser releases subdomain claims and hands released names to the
+// first user on their waitlist. Every path that gives up a claim (owner
+// deletion, inactivity expiry, failed liveness) goes through it.
+type ClaimReleaser struct {
+	config       *config.Config
+	claimRepo    *repositories.SubdomainClaimRepository
+	recordRepo   *repositories.RecordRepository
+	waitlistRepo *repositories.WaitlistRepository
+	notifier     services.Notifier
+}
+
+func NewClaimReleaser(config *config.Config, claimRepo *repositories.SubdomainClaimRepository, recordRepo *repositories.RecordRepository, waitlistRepo *repositories.WaitlistRepository, notifier services.Notifier) *ClaimReleaser {
+	return &ClaimReleaser{
+		config:       config,
+		claimRepo:    claimRepo,
+		recordRepo:   recordRepo,
+		waitlistRepo: waitlistRepo,
+		notifier:     notifier,
+	}
+}
+
+// Release deletes the claim, optionally together with all records under the
+// subdomain, and offers the name to the waitlist.
+func (r *ClaimReleaser) Release(ctx context.Context, claim *database.SubdomainClaim, deleteRecords bool) error {
+	if deleteRecords {
+		records, err := r.recordRepo.GetRecordsBySubdomain(ctx, r.fullDomain(claim.SubdomainName))
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			if err := r.recordRepo.DeleteRecord(ctx, record.ID); err != nil {
+				return fmt.Errorf("error deleting record %s: %v", record.RecordName, err)
+			}
+			events.Publish(events.RecordDeleted, record.UserId, record)
+		}
+	}
+
+	if err := r.claimRepo.DeleteClaim(ctx, claim.ID); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Released claim", "subdomain", claim.SubdomainName, "user_id", claim.UserId)
+	events.Publish(events.ClaimReleased, claim.UserId, claim)
+
+	if err := r.OfferToWaitlist(ctx, claim.SubdomainName); err != nil {
+		slog.ErrorContext(ctx, "Error offering released claim to waitlist", "subdomain", claim.SubdomainName, "error", err)
+	}
+
+	return nil
+}
+
+// OfferToWaitlist reserves a free name for the next waiting user and lets
+// the rest of the waitlist know the name was released.
+func (r *ClaimReleaser) OfferToWaitlist(ctx context.Context, subdomainName string) error {
+	reservation, waiting, err := r.waitlistRepo.PromoteNext(ctx, subdomainName, r.config.WaitlistReservationTTL)
+	if err != nil {
+		return fmt.Errorf("error promoting waitlist for %s: %v", subdomainName, err)
+	}
+	if reservation == nil {
+		return nil
+	}
+
+	domain := r.fullDomain(subdomainName)
+
+	r.notify(ctx, services.Notification{
+		UserID:  reservation.UserId,
+		Event:   services.NotificationWaitlistReserved,
+		Subject: fmt.Sprintf("%s is available for you", domain),
+		Message: fmt.Sprintf("The subdomain you were waiting for has been released and is reserved for you until %s. Claim it before then to keep it.", reservation.ExpiresAt),
+	})
+
+	for _, userID := range waiting {
+		r.notify(ctx, services.Notification{
+			UserID:  userID,
+			Event:   services.NotificationWaitlistReleased,
+			Subject: fmt.Sprintf("%s has been released", domain),
+			Message: "The subdomain you are waiting for has been released and offered to the next user on the waitlist. You keep your place in case they do not claim it.",
+		})
+	}
+
+	return nil
+}
+
+func (r *ClaimReleaser) notify(ctx context.Context, notification services.Notification) {
+	if err := r.notifier.Notify(ctx, notification); err != nil {
+		slog.ErrorContext(ctx, "Error sending notification", "event", notification.Event, "user_id", notification.UserID, "error", err)
+	}
+}
+
+func (r *ClaimReleaser) fullDomain(subdomainName string) string {
+	return subdomainName + "." + r.config.ParentDomain
+}
@@ -0,0 +1,105 @@
+package lifecycle
+
+import (
+	"btwarch/logging"
+	"btwarch/tracing"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a unit of periodic background work.
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+type scheduledJob struct {
+	job      Job
+	interval time.Duration
+}
+
+type Scheduler struct {
+	jobs []scheduledJob
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	// ctx is the parent of every job run. It is cancelled when a shutdown
+	// runs out of time, aborting runs still in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{stop: make(chan struct{}), ctx: ctx, cancel: cancel}
+}
+
+// Every registers a job to run at the given interval once the scheduler is
+// started. Jobs with a non-positive interval are ignored.
+func (s *Scheduler) Every(interval time.Duration, job Job) {
+	if interval <= 0 {
+		slog.Warn("Job has no interval, not scheduling", "job", job.Name())
+		return
+	}
+	s.jobs = append(s.jobs, scheduledJob{job: job, interval: interval})
+}
+
+func (s *Scheduler) Start() {
+	for _, sj := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(sj)
+	}
+}
+
+// Shutdown signals all jobs to stop and waits for any run in progress to
+// finish. If ctx is done first, the runs are cancelled and ctx's error is
+// returned.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	close(s.stop)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(sj scheduledJob) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(sj.interval)
+	defer ticker.Stop()
+
+	slog.Info("Scheduled job", "job", sj.job.Name(), "interval", sj.interval.String())
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.run(sj.job)
+		}
+	}
+}
+
+// run runs the job once as the root of its own trace.
+func (s *Scheduler) run(job Job) {
+	ctx, span := tracing.Start(logging.With(s.ctx, "job", job.Name()), "job "+job.Name())
+	err := job.Run(ctx)
+	tracing.End(span, err)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Job failed", "error", err)
+	}
+}
@@ -0,0 +1,51 @@
+package lifecycle
+
+import (
+	"btwarch/config"
+	"btwarch/logging"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// SecretReloadJob re-reads secrets kept in files, so rotated credentials are
+// picked up without a restart.
+type SecretReloadJob struct {
+	config *config.Config
+}
+
+func NewSecretReloadJob(config *config.Config) *SecretReloadJob {
+	return &SecretReloadJob{config: config}
+}
+
+func (j *SecretReloadJob) Name() string {
+	return "secret_reload"
+}
+
+func (j *SecretReloadJob) Run(ctx context.Context) error {
+	secrets := j.config.Secrets()
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	failed := 0
+	for _, key := range keys {
+		changed, err := secrets[key].Reload()
+		if err != nil {
+			slog.ErrorContext(ctx, "Error reloading secret, keeping the current value", "key", key, "error", err)
+			failed++
+			continue
+		}
+		if changed {
+			logging.Audit(ctx, "Rotated secret", "key", key, "file", secrets[key].Path())
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d secret(s) could not be reloaded", failed)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package lifecycle
+
+import (
+	"btwarch/events"
+	"btwarch/repositories"
+	"btwarch/services"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserSuspender suspends users and takes their records offline at Cloudflare
+// while keeping the rows, and lifts suspensions again.
+type UserSuspender struct {
+	userRepo   *repositories.UserRepository
+	recordRepo *repositories.RecordRepository
+	notifier   services.Notifier
+}
+
+func NewUserSuspender(userRepo *repositories.UserRepository, recordRepo *repositories.RecordRepository, notifier services.Notifier) *UserSuspender {
+	return &UserSuspender{
+		userRepo:   userRepo,
+		recordRepo: recordRepo,
+		notifier:   notifier,
+	}
+}
+
+// Suspend marks the user as suspended and deactivates all of their active
+// records. A nil until suspends the user indefinitely. Records that fail to
+// deactivate are logged and returned as an error after the rest were handled.
+func (s *UserSuspender) Suspend(ctx context.Context, userID uuid.UUID, reason string, until *time.Time) error {
+	if err := s.userRepo.SuspendUser(ctx, userID, reason, until); err != nil {
+		return err
+	}
+
+	records, err := s.recordRepo.GetRecordsByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, record := range records {
+		if !record.IsActive {
+			continue
+		}
+		if err := s.recordRepo.SuspendRecord(ctx, record.ID); err != nil {
+			slog.ErrorContext(ctx, "Error deactivating record of suspended user", "record", record.RecordName, "user_id", userID, "error", err)
+			failed++
+			continue
+		}
+		s.publishRecord(ctx, record.ID)
+	}
+
+	message := fmt.Sprintf("Your account has been suspended and your records have been deactivated. Reason: %s", reason)
+	if until != nil {
+		message += fmt.Sprintf(". The suspension ends on %s.", until.Format("2006-01-02 15:04 MST"))
+	}
+	s.notify(ctx, services.Notification{
+		UserID:  userID,
+		Event:   services.NotificationUserSuspended,
+		Subject: "Your account has been suspended",
+		Message: message,
+	})
+
+	if failed > 0 {
+		return fmt.Errorf("failed to deactivate %d of the user's records", failed)
+	}
+	return nil
+}
+
+// Unsuspend lifts the suspension. With restoreRecords the records taken down
+// by the suspension are recreated at Cloudflare, otherwise they stay inactive
+// and the owner can re-enable them one by one. Records that fail to restore
+// keep their suspended flag, are retried by the SuspensionExpiryJob and are
+// returned as an error.
+func (s *UserSuspender) Unsuspend(ctx context.Context, userID uuid.UUID, restoreRecords bool) error {
+	if err := s.userRepo.UnsuspendUser(ctx, userID); err != nil {
+		return err
+	}
+
+	failed := 0
+	if restoreRecords {
+		var err error
+		if failed, err = s.restoreRecords(ctx, userID); err != nil {
+			return err
+		}
+	} else if err := s.recordRepo.ClearSuspendedRecords(ctx, userID); err != nil {
+		return err
+	}
+
+	message := "Your account suspension has been lifted."
+	switch {
+	case !restoreRecords:
+		message += " Your records are still inactive and can be re-enabled from your dashboard."
+	case failed > 0:
+		message += " Some of your records could not be restored yet; they will be retried automatically."
+	default:
+		message += " Your records have been restored."
+	}
+	s.notify(ctx, services.Notification{
+		UserID:  userID,
+		Event:   services.NotificationUserUnsuspended,
+		Subject: "Your account suspension has been lifted",
+		Message: message,
+	})
+
+	if failed > 0 {
+		return fmt.Errorf("failed to restore %d of the user's records", failed)
+	}
+	return nil
+}
+
+// RetryRestore restores the records a lifted suspension failed to restore.
+func (s *UserSuspender) RetryRestore(ctx context.Context, userID uuid.UUID) error {
+	failed, err := s.restoreRecords(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to restore %d of the user's records", failed)
+	}
+	return nil
+}
+
+// restoreRecords recreates the user's suspended records and returns how many
+// failed. RestoreRecord clears the suspended flag of each record it restores,
+// so the failed ones can be retried.
+func (s *UserSuspender) restoreRecords(ctx context.Context, userID uuid.UUID) (int, error) {
+	records, err := s.recordRepo.GetSuspendedRecordsByUserID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, record := range records {
+		if err := s.recordRepo.RestoreRecord(ctx, record.ID); err != nil {
+			slog.ErrorContext(ctx, "Error restoring record", "record", record.RecordName, "user_id", userID, "error", err)
+			failed++
+			continue
+		}
+		s.publishRecord(ctx, record.ID)
+	}
+	return failed, nil
+}
+
+// publishRecord publishes the record's state after a suspension changed it.
+func (s *UserSuspender) publishRecord(ctx context.Context, recordID uuid.UUID) {
+	record, err := s.recordRepo.GetRecordByID(ctx, recordID)
+	if err != nil || record == nil {
+		slog.ErrorContext(ctx, "Error loading record to publish update", "record_id", recordID, "error", err)
+		return
+	}
+	events.Publish(events.RecordUpdated, record.UserId, record)
+}
+
+func (s *UserSuspender) notify(ctx context.Context, notification services.Notification) {
+	if err := s.notifier.Notify(ctx, notification); err != nil {
+		slog.ErrorContext(ctx, "Error sending notification", "event", notification.Event, "user_id", notification.UserID, "error", err)
+	}
+}
+
+// SuspensionExpiryJob lifts suspensions whose expiry has passed and restores
+// the records they took down, retrying records that failed to restore.
+type SuspensionExpiryJob struct {
+	userRepo   *repositories.UserRepository
+	recordRepo *repositories.RecordRepository
+	suspender  *UserSuspender
+}
+
+func NewSuspensionExpiryJob(userRepo *repositories.UserRepository, recordRepo *repositories.RecordRepository, suspender *UserSuspender) *SuspensionExpiryJob {
+	return &SuspensionExpiryJob{
+		userRepo:   userRepo,
+		recordRepo: recordRepo,
+		suspender:  suspender,
+	}
+}
+
+func (j *SuspensionExpiryJob) Name() string {
+	return "suspension-expiry"
+}
+
+func (j *SuspensionExpiryJob) Run(ctx context.Context) error {
+	users, err := j.userRepo.GetExpiredSuspensions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := j.suspender.Unsuspend(ctx, user.ID, true); err != nil {
+			slog.ErrorContext(ctx, "Error lifting expired suspension", "user_id", user.ID, "username", user.Username, "error", err)
+		}
+	}
+
+	userIDs, err := j.recordRepo.GetUsersWithUnrestoredRecords(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if err := j.suspender.RetryRestore(ctx, userID); err != nil {
+			slog.ErrorContext(ctx, "Error retrying record restore", "user_id", userID, "error", err)
+		}
+	}
+
+	return nil
+}
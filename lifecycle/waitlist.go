@@ -0,0 +1,41 @@
+package lifecycle
+
+import (
+	"btwarch/repositories"
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// WaitlistJob passes names whose reservation ran out unclaimed on to the next
+// user on the waitlist.
+type WaitlistJob struct {
+	waitlistRepo *repositories.WaitlistRepository
+	releaser     *ClaimReleaser
+}
+
+func NewWaitlistJob(waitlistRepo *repositories.WaitlistRepository, releaser *ClaimReleaser) *WaitlistJob {
+	return &WaitlistJob{
+		waitlistRepo: waitlistRepo,
+		releaser:     releaser,
+	}
+}
+
+func (j *WaitlistJob) Name() string {
+	return "waitlist"
+}
+
+func (j *WaitlistJob) Run(ctx context.Context) error {
+	names, err := j.waitlistRepo.GetExpiredReservationNames(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting expired reservations: %w", err)
+	}
+
+	for _, name := range names {
+		if err := j.releaser.OfferToWaitlist(ctx, name); err != nil {
+			slog.ErrorContext(ctx, "Error offering subdomain to waitlist", "subdomain", name, "error", err)
+		}
+	}
+
+	return nil
+}
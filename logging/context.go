@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type attrsKey struct{}
+
+type requestIDKey struct{}
+
+// With returns a context whose log lines carry the given key/value pairs in
+// addition to those already attached. A key that is already attached is
+// replaced.
+func With(ctx context.Context, args ...any) context.Context {
+	var record slog.Record
+	record.Add(args...)
+
+	existing := attrsFrom(ctx)
+	attrs := make([]slog.Attr, 0, len(existing)+record.NumAttrs())
+	added := make(map[string]bool, record.NumAttrs())
+	record.Attrs(func(a slog.Attr) bool {
+		added[a.Key] = true
+		return true
+	})
+	for _, a := range existing {
+		if !added[a.Key] {
+			attrs = append(attrs, a)
+		}
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// WithRequestID attaches the request ID to the context and its log lines.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(With(ctx, "request_id", requestID), requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID attached to the context, if any.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler adds the attributes attached with With to every record
+// logged with a context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs := attrsFrom(ctx); len(attrs) > 0 {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,118 @@
+// Package logging configures the process-wide structured logger and carries
+// request-scoped attributes, such as the request ID, through contexts.
+package logging
+
+import (
+	"btwarch/config"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are substrings of attribute keys whose values are never
+// written out, whatever their type.
+var sensitiveKeys = []string{"token", "secret", "password", "cookie", "authorization", "api_key"}
+
+// tokenPatterns match credentials that end up inside messages and errors, such
+// as an upstream response echoed in an error. The first group, when there is
+// one, is kept so that the line still shows what was removed.
+var tokenPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,})`),
+	regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`(?i)(\b(?:access_token|refresh_token|id_token|client_secret)=)[^&\s"']+`),
+	regexp.MustCompile(`(?i)("(?:access_token|refresh_token|id_token|client_secret)"\s*:\s*")[^"]*`),
+}
+
+// Setup installs the logger described by the configured level and format as
+// the default for both log/slog and the standard log package.
+func Setup(cfg *config.Config) *slog.Logger {
+	var level slog.Level
+	levelErr := level.UnmarshalText([]byte(cfg.LogLevel))
+	if levelErr != nil {
+		level = slog.LevelInfo
+	}
+
+	logger := New(os.Stderr, level, cfg.LogFormat)
+	slog.SetDefault(logger)
+
+	if levelErr != nil {
+		logger.Warn("Ignoring invalid LOG_LEVEL", "value", cfg.LogLevel)
+	}
+
+	return logger
+}
+
+// New returns a logger writing JSON, or logfmt-style text when format is
+// "text", that redacts secrets and adds the attributes carried by contexts.
+func New(w io.Writer, level slog.Leveler, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "text") {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+
+	key := strings.ToLower(a.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(a.Key, redacted)
+		}
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if scrubbed, ok := scrubTokens(a.Value.String()); ok {
+			return slog.String(a.Key, scrubbed)
+		}
+	case slog.KindAny:
+		if err, isErr := a.Value.Any().(error); isErr && err != nil {
+			if scrubbed, ok := scrubTokens(err.Error()); ok {
+				return slog.String(a.Key, scrubbed)
+			}
+		}
+	}
+
+	return a
+}
+
+// scrubTokens replaces the credentials tokenPatterns find in s. It reports
+// whether anything was replaced.
+func scrubTokens(s string) (string, bool) {
+	scrubbed := s
+	for _, pattern := range tokenPatterns {
+		scrubbed = pattern.ReplaceAllStringFunc(scrubbed, func(match string) string {
+			if groups := pattern.FindStringSubmatch(match); len(groups) > 1 {
+				return groups[1] + redacted
+			}
+			return redacted
+		})
+	}
+	return scrubbed, scrubbed != s
+}
+
+// Audit logs a privileged action. Audit lines are marked with audit=true so
+// they can be filtered and retained separately from operational logs.
+func Audit(ctx context.Context, msg string, args ...any) {
+	slog.InfoContext(ctx, msg, append([]any{"audit", true}, args...)...)
+}
+
+// Fatal logs msg at error level and exits, for failures during startup.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BusinessStats are the counts exported as business gauges.
+type BusinessStats struct {
+	Users               int
+	SuspendedUsers      int
+	ClaimsByStatus      map[string]int
+	ActiveRecordsByType map[string]int
+}
+
+var (
+	usersDesc = prometheus.NewDesc(namespace+"_users", "Registered users.", nil, nil)
+
+	suspendedUsersDesc = prometheus.NewDesc(namespace+"_users_suspended", "Users with a suspension in effect.", nil, nil)
+
+	claimsDesc = prometheus.NewDesc(namespace+"_claims", "Subdomain claims by status.", []string{"status"}, nil)
+
+	activeRecordsDesc = prometheus.NewDesc(namespace+"_records_active", "Active DNS records by type.", []string{"type"}, nil)
+)
+
+// businessCollector queries the stats on every scrape.
+type businessCollector struct {
+	source func(context.Context) (*BusinessStats, error)
+}
+
+// RegisterBusinessStats exposes the stats returned by source as gauges.
+func RegisterBusinessStats(source func(context.Context) (*BusinessStats, error)) {
+	Registry.MustRegister(&businessCollector{source: source})
+}
+
+func (c *businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- usersDesc
+	ch <- suspendedUsersDesc
+	ch <- claimsDesc
+	ch <- activeRecordsDesc
+}
+
+func (c *businessCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := c.source(context.Background())
+	if err != nil {
+		slog.Error("Error collecting business metrics", "error", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(usersDesc, prometheus.GaugeValue, float64(stats.Users))
+	ch <- prometheus.MustNewConstMetric(suspendedUsersDesc, prometheus.GaugeValue, float64(stats.SuspendedUsers))
+	for status, count := range stats.ClaimsByStatus {
+		ch <- prometheus.MustNewConstMetric(claimsDesc, prometheus.GaugeValue, float64(count), status)
+	}
+	for recordType, count := range stats.ActiveRecordsByType {
+		ch <- prometheus.MustNewConstMetric(activeRecordsDesc, prometheus.GaugeValue, float64(count), recordType)
+	}
+}
@@ -1,31 +1,49 @@
 package middleware
 
 import (
+	"btwarch/problem"
+	"btwarch/repositories"
 	"btwarch/services"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
-func AuthMiddleware(authService *services.AuthService) fiber.Handler {
+func AuthMiddleware(authService *services.AuthService, userRepository *repositories.UserRepository) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 
 		authCookie := c.Cookies("auth_token")
 		if authCookie == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Authentication cookie is required",
-			})
+			return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "Authentication cookie is required")
 		}
 
 		claims, err := authService.ValidateToken(authCookie)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid or expired authentication",
-			})
+			return problem.New(fiber.StatusUnauthorized, problem.CodeInvalidToken, "Invalid or expired authentication")
+		}
+
+		userID, err := uuid.Parse(claims.UserID)
+		if err != nil {
+			return problem.New(fiber.StatusUnauthorized, problem.CodeInvalidToken, "Invalid or expired authentication")
+		}
+
+		user, err := userRepository.GetUserByID(c.UserContext(), userID)
+		if err != nil {
+			return problem.Internal(err)
+		}
+		if user == nil {
+			return problem.New(fiber.StatusUnauthorized, problem.CodeInvalidToken, "Invalid or expired authentication")
+		}
+		if user.Suspended {
+			return problem.New(fiber.StatusForbidden, problem.CodeAccountSuspended, "Account suspended").
+				With("reason", user.SuspensionReason).
+				With("suspended_until", user.SuspendedUntil)
 		}
 
 		c.Locals("user_id", claims.UserID)
 		c.Locals("username", claims.Username)
 		c.Locals("avatar_url", claims.AvatarURL)
+		AddLogAttrs(c, "user_id", claims.UserID)
 
 		return c.Next()
 	}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"btwarch/config"
+	"log/slog"
+	"net/netip"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientIPMiddleware works out the real client address. When the connection
+// comes from a trusted proxy, X-Forwarded-For is walked from the right and the
+// first address that is not a trusted proxy is used.
+func ClientIPMiddleware(config *config.Config) fiber.Handler {
+	trusted := parsePrefixes(config.TrustedProxies, "trusted proxy")
+
+	isTrusted := func(addr netip.Addr) bool {
+		return prefixesContain(trusted, addr)
+	}
+
+	return func(c *fiber.Ctx) error {
+		addr, ok := netip.AddrFromSlice(c.Context().RemoteIP())
+		if !ok {
+			return c.Next()
+		}
+		addr = addr.Unmap()
+
+		if isTrusted(addr) {
+			hops := strings.Split(c.Get(fiber.HeaderXForwardedFor), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+				if err != nil {
+					break
+				}
+				addr = hop.Unmap()
+				if !isTrusted(addr) {
+					break
+				}
+			}
+		}
+
+		c.Locals("client_ip", addr.String())
+
+		return c.Next()
+	}
+}
+
+// ClientIP returns the address found by ClientIPMiddleware, falling back to
+// the connection's remote address.
+func ClientIP(c *fiber.Ctx) string {
+	if ip, ok := c.Locals("client_ip").(string); ok && ip != "" {
+		return ip
+	}
+	return c.IP()
+}
+
+// parsePrefixes parses a list of IPs and CIDRs, logging and skipping invalid
+// entries.
+func parsePrefixes(entries []string, what string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		slog.Warn("Ignoring invalid "+what, "value", entry)
+	}
+	return prefixes
+}
+
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -26,7 +26,8 @@ func CorsMiddleware(config *config.Config) fiber.Handler {
 		}
 
 		c.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-HTTP-Method-Override, Accept")
+		c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-HTTP-Method-Override, Accept, X-Request-ID")
+		c.Set("Access-Control-Expose-Headers", "X-Request-ID")
 		c.Set("Access-Control-Allow-Credentials", "true")
 
 		if c.Method() == "OPTIONS" {
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"btwarch/problem"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DeprecatedAliasMiddleware serves requests to the unversioned paths under
+// prefixes by rewriting them onto their successor under version. The response
+// announces the deprecation with the Deprecation and Link headers.
+func DeprecatedAliasMiddleware(version string, prefixes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		path := c.Path()
+		if !hasPathPrefix(path, prefixes) {
+			return c.Next()
+		}
+
+		successor := version + path
+		c.Set("Deprecation", "true")
+		c.Set(fiber.HeaderLink, "<"+successor+`>; rel="successor-version"`)
+		c.Locals(problem.LegacyLocal, true)
+		AddLogAttrs(c, "deprecated_path", path)
+
+		c.Path(successor)
+		return c.Next()
+	}
+}
+
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"btwarch/config"
+	"btwarch/metrics"
+	"btwarch/problem"
+	"crypto/subtle"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MetricsMiddleware records the count and latency of every request, labelled
+// with the route pattern rather than the raw path to keep cardinality low.
+func MetricsMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := responseStatus(c, err)
+		route := routeLabel(c, err)
+
+		metrics.ObserveHTTPRequest(c.Method(), route, strconv.Itoa(status), time.Since(start))
+
+		return err
+	}
+}
+
+// MetricsAccessMiddleware lets requests from the allowed addresses through,
+// as well as requests carrying the configured bearer token.
+func MetricsAccessMiddleware(config *config.Config) fiber.Handler {
+	allowed := parsePrefixes(config.MetricsAllowedIPs, "metrics address")
+	expected := []byte("Bearer " + config.MetricsToken)
+
+	return func(c *fiber.Ctx) error {
+		if config.MetricsToken != "" && subtle.ConstantTimeCompare([]byte(c.Get(fiber.HeaderAuthorization)), expected) == 1 {
+			return c.Next()
+		}
+
+		if addr, err := netip.ParseAddr(ClientIP(c)); err == nil && prefixesContain(allowed, addr.Unmap()) {
+			return c.Next()
+		}
+
+		return problem.New(fiber.StatusForbidden, problem.CodeForbidden, "forbidden")
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"btwarch/config"
+	"btwarch/problem"
+	"btwarch/ratelimit"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimitMiddleware applies a token bucket per authenticated user, or per
+// client IP for anonymous requests. name separates the buckets of different
+// route groups. It sets the RateLimit-* headers on every response and
+// Retry-After when the limit is exceeded. If the store fails the request is
+// let through.
+func RateLimitMiddleware(store ratelimit.Store, name string, limit config.RateLimit) fiber.Handler {
+	if !limit.Enabled() {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Period.Seconds()))
+
+	return func(c *fiber.Ctx) error {
+		key := name + ":ip:" + ClientIP(c)
+		if userID, ok := c.Locals("user_id").(string); ok && userID != "" {
+			key = name + ":user:" + userID
+		}
+
+		result, err := store.Take(c.UserContext(), key, limit)
+		if err != nil {
+			slog.ErrorContext(c.UserContext(), "Error checking rate limit", "key", key, "error", err)
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Policy", policy)
+		c.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			return problem.New(fiber.StatusTooManyRequests, problem.CodeRateLimited, "rate limit exceeded, please try again later")
+		}
+
+		return c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
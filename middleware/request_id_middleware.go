@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"btwarch/logging"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware gives every request an ID, reusing a well-formed
+// X-Request-ID header from the client or proxy. The ID is echoed in the
+// response and attached to the request context so log lines carry it.
+func RequestIDMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(fiber.HeaderXRequestID)
+		if validRequestID(requestID) {
+			requestID = strings.Clone(requestID)
+		} else {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(fiber.HeaderXRequestID, requestID)
+		c.Locals("request_id", requestID)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), requestID))
+
+		return c.Next()
+	}
+}
+
+// AddLogAttrs attaches key/value pairs to the log lines written for the rest
+// of the request, including its access log line.
+func AddLogAttrs(c *fiber.Ctx, args ...any) {
+	c.SetUserContext(logging.With(c.UserContext(), args...))
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range requestID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
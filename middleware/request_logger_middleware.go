@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"btwarch/problem"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestLoggerMiddleware writes one access log line per request. The query
+// string is left out since some links carry tokens in it.
+func RequestLoggerMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := responseStatus(c, err)
+		route := routeLabel(c, err)
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", len(c.Response().Body())),
+			slog.String("ip", ClientIP(c)),
+			slog.String("user_agent", c.Get(fiber.HeaderUserAgent)),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+
+		slog.LogAttrs(c.UserContext(), level, "Request handled", attrs...)
+
+		return err
+	}
+}
+
+// responseStatus returns the status the client will see, including when a
+// handler returned an error that the error handler has yet to write.
+func responseStatus(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	return problem.From(err).Status
+}
+
+// routeLabel returns the pattern of the route that handled the request, or
+// "unmatched" when no route did.
+func routeLabel(c *fiber.Ctx, err error) string {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusNotFound {
+		return "unmatched"
+	}
+	return c.Route().Path
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"btwarch/problem"
+	"btwarch/repositories"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RequireRole only lets through users whose current role, as stored in the
+// database, matches the given role. It must run after AuthMiddleware.
+func RequireRole(userRepository *repositories.UserRepository, role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userIDStr, ok := c.Locals("user_id").(string)
+		if !ok || userIDStr == "" {
+			return problem.New(fiber.StatusUnauthorized, problem.CodeUnauthenticated, "Authentication is required")
+		}
+
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			return problem.New(fiber.StatusUnauthorized, problem.CodeInvalidToken, "Invalid or expired authentication")
+		}
+
+		user, err := userRepository.GetUserByID(c.UserContext(), userID)
+		if err != nil {
+			return problem.Internal(err)
+		}
+
+		if user == nil || user.Role != role {
+			return problem.New(fiber.StatusForbidden, problem.CodeForbidden, "Insufficient permissions")
+		}
+
+		c.Locals("role", user.Role)
+
+		return c.Next()
+	}
+}
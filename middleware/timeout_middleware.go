@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TimeoutMiddleware puts a deadline on the request context. fasthttp gives no
+// signal when a client goes away, so the deadline is what stops database and
+// upstream calls made for an abandoned request. Handlers answer requests that
+// run out of time with 504 Gateway Timeout.
+func TimeoutMiddleware(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if timeout <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"btwarch/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware starts a server span for every request, continuing a
+// trace passed in the traceparent header. The span is carried in the request
+// context, so repository and Cloudflare spans become its children, and the
+// trace ID is added to the request's log lines.
+func TracingMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), requestHeaderCarrier{c})
+		ctx, span := tracing.Start(ctx, c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Method()),
+				attribute.String("url.path", c.Path()),
+				attribute.String("client.address", ClientIP(c)),
+				attribute.String("user_agent.original", c.Get(fiber.HeaderUserAgent)),
+			),
+		)
+		defer span.End()
+
+		if requestID, ok := c.Locals("request_id").(string); ok {
+			span.SetAttributes(attribute.String("request_id", requestID))
+		}
+		c.SetUserContext(ctx)
+		if spanContext := span.SpanContext(); spanContext.IsSampled() {
+			AddLogAttrs(c, "trace_id", spanContext.TraceID().String())
+		}
+
+		err := c.Next()
+
+		status := responseStatus(c, err)
+		route := routeLabel(c, err)
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", status),
+		)
+		if userID, ok := c.Locals("user_id").(string); ok {
+			span.SetAttributes(attribute.String("enduser.id", userID))
+		}
+		if status >= fiber.StatusInternalServerError {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.SetStatus(codes.Error, "")
+		}
+
+		return err
+	}
+}
+
+// requestHeaderCarrier reads trace propagation headers from the request.
+type requestHeaderCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h requestHeaderCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h requestHeaderCarrier) Set(key, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h requestHeaderCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
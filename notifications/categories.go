@@ -0,0 +1,57 @@
+package notifications
+
+import "btwarch/services"
+
+const (
+	CategoryClaims     = "claims"
+	CategoryRecords    = "records"
+	CategoryExpiry     = "expiry"
+	CategorySecurity   = "security"
+	CategoryModeration = "moderation"
+)
+
+// Categories lists the notification categories users can turn on and off.
+var Categories = []string{CategoryClaims, CategoryRecords, CategoryExpiry, CategorySecurity, CategoryModeration}
+
+var eventCategories = map[string]string{
+	services.NotificationClaimCreated:     CategoryClaims,
+	services.NotificationClaimReleased:    CategoryClaims,
+	services.NotificationWaitlistReserved: CategoryClaims,
+	services.NotificationWaitlistReleased: CategoryClaims,
+
+	services.NotificationRecordChanged: CategoryRecords,
+
+	services.NotificationClaimInactive: CategoryExpiry,
+	services.NotificationClaimCooldown: CategoryExpiry,
+
+	services.NotificationNewDevice: CategorySecurity,
+
+	services.NotificationUserSuspended:   CategoryModeration,
+	services.NotificationUserUnsuspended: CategoryModeration,
+	services.NotificationRecordDisabled:  CategoryModeration,
+	services.NotificationClaimRevoked:    CategoryModeration,
+}
+
+// CategoryFor returns the category of a notification event. Unknown events
+// are treated as moderation notices so they are never dropped silently.
+func CategoryFor(event string) string {
+	if category, ok := eventCategories[event]; ok {
+		return category
+	}
+	return CategoryModeration
+}
+
+// IsMandatory reports whether a category cannot be turned off. Users always
+// hear about moderation actions taken against them.
+func IsMandatory(category string) bool {
+	return category == CategoryModeration
+}
+
+func IsCategory(category string) bool {
+	for _, c := range Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
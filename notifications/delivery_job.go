@@ -0,0 +1,88 @@
+package notifications
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/repositories"
+	"btwarch/services"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	emailBatchSize  = 20
+	emailRetryBase  = time.Minute
+	emailRetryLimit = time.Hour
+)
+
+// EmailDeliveryJob sends queued emails and retries failed sends with
+// exponential backoff until the attempt limit is reached.
+type EmailDeliveryJob struct {
+	config           *config.Config
+	notificationRepo *repositories.NotificationRepository
+	mailer           services.Mailer
+}
+
+func NewEmailDeliveryJob(config *config.Config, notificationRepo *repositories.NotificationRepository, mailer services.Mailer) *EmailDeliveryJob {
+	return &EmailDeliveryJob{
+		config:           config,
+		notificationRepo: notificationRepo,
+		mailer:           mailer,
+	}
+}
+
+func (j *EmailDeliveryJob) Name() string {
+	return "email-delivery"
+}
+
+func (j *EmailDeliveryJob) Run(ctx context.Context) error {
+	// Lease the batch for longer than it can take to send every email in it.
+	lease := time.Duration(emailBatchSize+1) * j.config.SMTPTimeout
+
+	emails, err := j.notificationRepo.ClaimDueEmails(ctx, emailBatchSize, lease)
+	if err != nil {
+		return err
+	}
+
+	for _, email := range emails {
+		j.send(ctx, email)
+	}
+
+	return nil
+}
+
+func (j *EmailDeliveryJob) send(ctx context.Context, email *database.OutgoingEmail) {
+	err := j.mailer.Send(services.EmailMessage{
+		To:       email.ToAddress,
+		Subject:  email.Subject,
+		TextBody: email.TextBody,
+		HTMLBody: email.HTMLBody,
+	})
+	if err == nil {
+		if err := j.notificationRepo.MarkEmailSent(ctx, email.ID); err != nil {
+			slog.ErrorContext(ctx, "Error updating email", "email_id", email.ID, "error", err)
+		}
+		return
+	}
+
+	attempts := email.Attempts + 1
+	var nextAttemptAt *time.Time
+	message := err.Error()
+	if attempts < j.config.EmailMaxAttempts {
+		delay := emailRetryBase << (attempts - 1)
+		if delay > emailRetryLimit || delay <= 0 {
+			delay = emailRetryLimit
+		}
+		next := time.Now().Add(delay)
+		nextAttemptAt = &next
+	} else {
+		message = fmt.Sprintf("%s (giving up after %d attempts)", message, attempts)
+		slog.WarnContext(ctx, "Giving up on email", "event", email.Event, "to", email.ToAddress, "error", err)
+	}
+
+	if err := j.notificationRepo.MarkEmailFailed(ctx, email.ID, message, nextAttemptAt); err != nil {
+		slog.ErrorContext(ctx, "Error updating email", "email_id", email.ID, "error", err)
+	}
+}
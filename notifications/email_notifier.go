@@ -0,0 +1,115 @@
+package notifications
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/repositories"
+	"btwarch/services"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+// EmailNotifier queues notifications as emails to the user's verified
+// override address, or their GitHub email otherwise, unless the user turned
+// the notification's category off. The email delivery job sends the queue.
+type EmailNotifier struct {
+	config           *config.Config
+	userRepo         *repositories.UserRepository
+	notificationRepo *repositories.NotificationRepository
+}
+
+func NewEmailNotifier(config *config.Config, userRepo *repositories.UserRepository, notificationRepo *repositories.NotificationRepository) *EmailNotifier {
+	return &EmailNotifier{
+		config:           config,
+		userRepo:         userRepo,
+		notificationRepo: notificationRepo,
+	}
+}
+
+// NewNotifier returns an email notifier when SMTP is configured and a
+// notifier that only logs otherwise.
+func NewNotifier(config *config.Config) services.Notifier {
+	if !config.EmailEnabled() {
+		return services.NewLogNotifier()
+	}
+	return NewEmailNotifier(config, repositories.NewUserRepository(), repositories.NewNotificationRepository())
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, notification services.Notification) error {
+	user, err := n.userRepo.GetUserByID(ctx, notification.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user %s not found", notification.UserID)
+	}
+
+	prefs, err := n.notificationRepo.GetPreferences(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	category := CategoryFor(notification.Event)
+	if !IsMandatory(category) && prefs != nil && contains(prefs.DisabledCategories, category) {
+		return nil
+	}
+
+	address := EmailAddress(user, prefs)
+	if address == "" {
+		slog.InfoContext(ctx, "Notification not emailed: no email address", "event", notification.Event, "user_id", user.ID)
+		return nil
+	}
+
+	text, html, err := render("notification", map[string]string{
+		"Username":     user.Username,
+		"Subject":      notification.Subject,
+		"Message":      notification.Message,
+		"ParentDomain": n.config.ParentDomain,
+		"SettingsURL":  n.config.AppURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	return n.notificationRepo.QueueEmail(ctx, &user.ID, address, notification.Event, notification.Subject, text, html)
+}
+
+// SendVerification emails the link that confirms a new override address.
+// It goes out regardless of the user's preferences.
+func (n *EmailNotifier) SendVerification(ctx context.Context, user *database.User, address, token string, expiresAt time.Time) error {
+	verifyURL := n.config.PublicURL + "/v1/notifications/email/verify?token=" + url.QueryEscape(token)
+
+	text, html, err := render("verify_email", map[string]string{
+		"Username":     user.Username,
+		"ParentDomain": n.config.ParentDomain,
+		"VerifyURL":    verifyURL,
+		"ExpiresAt":    expiresAt.UTC().Format("2006-01-02 15:04 MST"),
+	})
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("Confirm your %s notification email", n.config.ParentDomain)
+	return n.notificationRepo.QueueEmail(ctx, &user.ID, address, repositories.VerificationEmailEvent, subject, text, html)
+}
+
+// EmailAddress returns where a user's notifications go: the verified
+// override if there is one, their GitHub email otherwise.
+func EmailAddress(user *database.User, prefs *database.NotificationPreferences) string {
+	if prefs != nil && prefs.EmailOverride != nil && prefs.EmailOverrideVerifiedAt != nil {
+		return *prefs.EmailOverride
+	}
+	return user.Email
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
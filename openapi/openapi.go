@@ -0,0 +1,68 @@
+// Package openapi holds the OpenAPI document describing the HTTP API and the
+// page that renders it.
+package openapi
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the OpenAPI 3.1 document as written, in YAML.
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+// DocsPage is an HTML page rendering the document served at /openapi.json.
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// swaggerUI is where DocsPage loads swagger-ui from, pinned to one version.
+const swaggerUI = "https://unpkg.com/swagger-ui-dist@5.17.14/"
+
+var inlineScript = regexp.MustCompile(`(?s)<script>(.*?)</script>`)
+
+// DocsContentSecurityPolicy returns the Content-Security-Policy DocsPage is
+// served with. Scripts and styles may only come from the pinned swagger-ui
+// files and the page's own inline script, and the page may only talk to
+// this origin.
+func DocsContentSecurityPolicy() string {
+	scripts := []string{swaggerUI + "swagger-ui-bundle.js"}
+	for _, match := range inlineScript.FindAllSubmatch(DocsPage, -1) {
+		sum := sha256.Sum256(match[1])
+		scripts = append(scripts, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+	}
+
+	return strings.Join([]string{
+		"default-src 'none'",
+		"script-src " + strings.Join(scripts, " "),
+		// swagger-ui sets inline styles on the elements it renders.
+		"style-src " + swaggerUI + "swagger-ui.css 'unsafe-inline'",
+		"img-src 'self' data:",
+		"connect-src 'self'",
+		"base-uri 'none'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+	}, "; ")
+}
+
+// JSON returns the document converted to JSON.
+func JSON() ([]byte, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(Spec, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing OpenAPI document: %w", err)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding OpenAPI document: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,151 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// AddressRule rejects A and AAAA values inside any of its prefixes.
+type AddressRule struct {
+	name        string
+	description string
+	prefixes    []netip.Prefix
+}
+
+func NewAddressRule(name, description string, prefixes ...string) *AddressRule {
+	rule := &AddressRule{name: name, description: description}
+	for _, prefix := range prefixes {
+		rule.prefixes = append(rule.prefixes, netip.MustParsePrefix(prefix))
+	}
+	return rule
+}
+
+// DefaultAddressRules covers loopback, private, link-local and bogon ranges.
+func DefaultAddressRules() []Rule {
+	return []Rule{
+		NewAddressRule("loopback", "a loopback address",
+			"127.0.0.0/8", "::1/128"),
+		NewAddressRule("private", "a private network address (RFC 1918 / RFC 4193)",
+			"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"),
+		NewAddressRule("link-local", "a link-local address",
+			"169.254.0.0/16", "fe80::/10"),
+		NewAddressRule("bogon", "a reserved or non-routable address",
+			"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "192.0.2.0/24",
+			"198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24",
+			"224.0.0.0/4", "240.0.0.0/4",
+			"::/128", "100::/64", "2001:db8::/32", "ff00::/8"),
+	}
+}
+
+// CheckPublicAddress returns the violation of the first DefaultAddressRules
+// rule the IP address falls under. Addresses with a zone are rejected, since
+// they only mean something on the host's own interfaces. Values that are not
+// IP addresses pass.
+func CheckPublicAddress(ip string) *Violation {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	if addr.Zone() != "" {
+		return &Violation{Reason: fmt.Sprintf("%s is scoped to a network interface and cannot be used", addr)}
+	}
+
+	recordType := "A"
+	if addr.Unmap().Is6() {
+		recordType = "AAAA"
+	}
+
+	for _, rule := range DefaultAddressRules() {
+		violation, _ := rule.Check(context.Background(), Target{RecordType: recordType, Value: ip})
+		if violation != nil {
+			return violation
+		}
+	}
+	return nil
+}
+
+func (r *AddressRule) Name() string {
+	return r.name
+}
+
+func (r *AddressRule) Check(ctx context.Context, target Target) (*Violation, error) {
+	if target.RecordType != "A" && target.RecordType != "AAAA" {
+		return nil, nil
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSpace(target.Value))
+	if err != nil {
+		return nil, nil
+	}
+	addr = addr.Unmap().WithZone("")
+
+	candidates := []netip.Addr{addr}
+	if embedded, ok := embeddedIPv4(addr); ok {
+		candidates = append(candidates, embedded)
+	}
+
+	for _, candidate := range candidates {
+		for _, prefix := range r.prefixes {
+			if prefix.Contains(candidate) {
+				return &Violation{Reason: fmt.Sprintf("%s is %s and cannot be published (%s)", addr, r.description, prefix)}, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+var (
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFour   = netip.MustParsePrefix("2002::/16")
+)
+
+// embeddedIPv4 returns the IPv4 address a NAT64 (64:ff9b::/96) or 6to4
+// (2002::/16) address leads to, so that it is held to the same rules.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	b := addr.As16()
+	switch {
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), true
+	case sixToFour.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}), true
+	}
+	return netip.Addr{}, false
+}
+
+// ReservedNameRule rejects CNAME targets under special-use names that never
+// resolve on the public internet.
+type ReservedNameRule struct {
+	suffixes []string
+}
+
+func NewReservedNameRule() *ReservedNameRule {
+	return &ReservedNameRule{
+		suffixes: []string{"localhost", "local", "internal", "invalid", "test", "example", "home.arpa", "onion"},
+	}
+}
+
+func (r *ReservedNameRule) Name() string {
+	return "reserved-name"
+}
+
+func (r *ReservedNameRule) Check(ctx context.Context, target Target) (*Violation, error) {
+	if target.RecordType != "CNAME" {
+		return nil, nil
+	}
+
+	host := normalizeHostname(target.Value)
+	for _, suffix := range r.suffixes {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return &Violation{Reason: fmt.Sprintf("CNAME target %s is under the special-use name .%s and cannot be published", host, suffix)}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func normalizeHostname(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
@@ -0,0 +1,44 @@
+package policy
+
+import "testing"
+
+func TestCheckPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip     string
+		reject bool
+	}{
+		{ip: "1.1.1.1"},
+		{ip: "2606:4700:4700::1111"},
+		{ip: "::ffff:1.1.1.1"},
+		{ip: "64:ff9b::101:101"},
+		{ip: "2002:101:101::1"},
+		{ip: "not-an-address"},
+		{ip: "127.0.0.1", reject: true},
+		{ip: "::1", reject: true},
+		{ip: "10.1.2.3", reject: true},
+		{ip: "192.168.1.1", reject: true},
+		{ip: "fd00::1", reject: true},
+		{ip: "169.254.169.254", reject: true},
+		{ip: "fe80::1", reject: true},
+		{ip: "fe80::1%eth0", reject: true},
+		{ip: "2606:4700:4700::1111%eth0", reject: true},
+		{ip: "::ffff:127.0.0.1", reject: true},
+		{ip: "64:ff9b::7f00:1", reject: true},
+		{ip: "64:ff9b::a9fe:a9fe", reject: true},
+		{ip: "2002:c0a8:101::1", reject: true},
+		{ip: "2002:a00:1::", reject: true},
+		{ip: "0.0.0.0", reject: true},
+		{ip: "::", reject: true},
+		{ip: "224.0.0.1", reject: true},
+	}
+
+	for _, tt := range tests {
+		violation := CheckPublicAddress(tt.ip)
+		if tt.reject && violation == nil {
+			t.Errorf("CheckPublicAddress(%s) passed, want a violation", tt.ip)
+		}
+		if !tt.reject && violation != nil {
+			t.Errorf("CheckPublicAddress(%s) = %v, want it to pass", tt.ip, violation)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// Blocklist rejects targets listed in a local file. Each line holds a domain,
+// which also blocks its subdomains, an IP address or a CIDR prefix. Blank
+// lines and text after # are ignored.
+type Blocklist struct {
+	domains  map[string]bool
+	prefixes []netip.Prefix
+}
+
+func LoadBlocklist(path string) (*Blocklist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening blocklist: %w", err)
+	}
+	defer file.Close()
+
+	blocklist := &Blocklist{domains: map[string]bool{}}
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if prefix, err := netip.ParsePrefix(line); err == nil {
+			blocklist.prefixes = append(blocklist.prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(line); err == nil {
+			blocklist.prefixes = append(blocklist.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		domain := normalizeHostname(strings.TrimPrefix(line, "*."))
+		if domain == "" || strings.ContainsAny(domain, " /") {
+			return nil, fmt.Errorf("invalid blocklist entry on line %d: %q", lineNumber, line)
+		}
+		blocklist.domains[domain] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading blocklist: %w", err)
+	}
+
+	return blocklist, nil
+}
+
+func (b *Blocklist) Len() int {
+	return len(b.domains) + len(b.prefixes)
+}
+
+func (b *Blocklist) Name() string {
+	return "blocklist"
+}
+
+func (b *Blocklist) Check(ctx context.Context, target Target) (*Violation, error) {
+	switch target.RecordType {
+	case "A", "AAAA":
+		addr, err := netip.ParseAddr(strings.TrimSpace(target.Value))
+		if err != nil {
+			return nil, nil
+		}
+		addr = addr.Unmap()
+
+		for _, prefix := range b.prefixes {
+			if prefix.Contains(addr) {
+				return &Violation{Reason: fmt.Sprintf("%s is on the blocklist (%s)", addr, prefix)}, nil
+			}
+		}
+	case "CNAME":
+		host := normalizeHostname(target.Value)
+		for name := host; name != ""; {
+			if b.domains[name] {
+				return &Violation{Reason: fmt.Sprintf("CNAME target %s is on the blocklist (%s)", host, name)}, nil
+			}
+
+			i := strings.Index(name, ".")
+			if i < 0 {
+				break
+			}
+			name = name[i+1:]
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"btwarch/config"
+	"btwarch/repositories"
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+const (
+	ActionReject = "reject"
+	ActionFlag   = "flag"
+)
+
+// Target is the record a policy decides on.
+type Target struct {
+	RecordName string
+	RecordType string
+	Value      string
+}
+
+// Violation describes why a rule objected to a target.
+type Violation struct {
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+func (v *Violation) Error() string {
+	return v.Reason
+}
+
+// Rule is a single target check. Check returns nil when the target passes.
+type Rule interface {
+	Name() string
+	Check(ctx context.Context, target Target) (*Violation, error)
+}
+
+// Decision is the outcome of evaluating a target against every rule.
+type Decision struct {
+	Rejection *Violation
+	Flags     []*Violation
+}
+
+// TargetPolicy runs record targets through a list of rules. Rules listed as
+// flag-only let the record through but report the violation as a flag.
+type TargetPolicy struct {
+	rules    []Rule
+	flagOnly map[string]bool
+}
+
+func NewTargetPolicy(rules []Rule, flagOnly []string) *TargetPolicy {
+	p := &TargetPolicy{rules: rules, flagOnly: map[string]bool{}}
+	for _, name := range flagOnly {
+		p.flagOnly[name] = true
+	}
+	return p
+}
+
+// NewDefaultTargetPolicy builds the policy used by the API: address range
+// checks, reserved names, CNAME loops within the parent zone and, when
+// configured, the local blocklist file.
+func NewDefaultTargetPolicy(cfg *config.Config, recordRepo *repositories.RecordRepository) (*TargetPolicy, error) {
+	rules := append(DefaultAddressRules(), NewReservedNameRule(), NewZoneLoopRule(cfg.ParentDomain, recordRepo))
+
+	if cfg.TargetBlocklistFile != "" {
+		blocklist, err := LoadBlocklist(cfg.TargetBlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Loaded target blocklist", "entries", blocklist.Len(), "file", cfg.TargetBlocklistFile)
+		rules = append(rules, blocklist)
+	}
+
+	return NewTargetPolicy(rules, cfg.TargetPolicyFlagRules), nil
+}
+
+// Evaluate checks the target against all rules. It stops at the first
+// rejection; flags are collected along the way.
+func (p *TargetPolicy) Evaluate(ctx context.Context, target Target) (*Decision, error) {
+	decision := &Decision{}
+
+	for _, rule := range p.rules {
+		violation, err := rule.Check(ctx, target)
+		if err != nil {
+			return nil, fmt.Errorf("error running %s check: %v", rule.Name(), err)
+		}
+		if violation == nil {
+			continue
+		}
+
+		violation.Rule = rule.Name()
+		if p.flagOnly[rule.Name()] {
+			violation.Action = ActionFlag
+			decision.Flags = append(decision.Flags, violation)
+			continue
+		}
+
+		violation.Action = ActionReject
+		decision.Rejection = violation
+		return decision, nil
+	}
+
+	return decision, nil
+}
@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"btwarch/repositories"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const maxCNAMEChainLength = 8
+
+// ZoneLoopRule follows CNAME targets inside the parent zone and rejects
+// records that would form a loop or an overly long chain.
+type ZoneLoopRule struct {
+	parentDomain string
+	recordRepo   *repositories.RecordRepository
+}
+
+func NewZoneLoopRule(parentDomain string, recordRepo *repositories.RecordRepository) *ZoneLoopRule {
+	return &ZoneLoopRule{
+		parentDomain: normalizeHostname(parentDomain),
+		recordRepo:   recordRepo,
+	}
+}
+
+func (r *ZoneLoopRule) Name() string {
+	return "cname-loop"
+}
+
+func (r *ZoneLoopRule) Check(ctx context.Context, target Target) (*Violation, error) {
+	if target.RecordType != "CNAME" {
+		return nil, nil
+	}
+
+	recordName := normalizeHostname(target.RecordName)
+	current := normalizeHostname(target.Value)
+	chain := []string{recordName}
+	seen := map[string]bool{recordName: true}
+
+	for len(chain) <= maxCNAMEChainLength {
+		if !r.inZone(current) {
+			return nil, nil
+		}
+
+		chain = append(chain, current)
+		if seen[current] {
+			return &Violation{Reason: fmt.Sprintf("CNAME target creates a loop inside %s: %s", r.parentDomain, strings.Join(chain, " -> "))}, nil
+		}
+		seen[current] = true
+
+		next, err := r.recordRepo.GetRecordByNameAndType(ctx, current, "CNAME")
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			return nil, nil
+		}
+		current = normalizeHostname(next.RecordValue)
+	}
+
+	return &Violation{Reason: fmt.Sprintf("CNAME chain inside %s is longer than %d hops: %s", r.parentDomain, maxCNAMEChainLength, strings.Join(chain, " -> "))}, nil
+}
+
+func (r *ZoneLoopRule) inZone(host string) bool {
+	return host == r.parentDomain || strings.HasSuffix(host, "."+r.parentDomain)
+}
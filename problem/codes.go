@@ -0,0 +1,75 @@
+package problem
+
+import "github.com/gofiber/fiber/v2"
+
+// Codes identify what went wrong independently of the wording of the detail.
+// Once published a code keeps its meaning; new situations get new codes.
+const (
+	CodeInvalidRequest  = "invalid_request"
+	CodeInvalidBody     = "invalid_body"
+	CodeInvalidID       = "invalid_id"
+	CodeValidation      = "validation_failed"
+	CodePolicyViolation = "policy_violation"
+	CodeSelfAction      = "self_action_not_allowed"
+	CodeNotOptional     = "notification_not_optional"
+
+	CodeUnauthenticated = "unauthenticated"
+	CodeInvalidToken    = "invalid_token"
+
+	CodeForbidden          = "forbidden"
+	CodeAccountSuspended   = "account_suspended"
+	CodeSubdomainNotOwned  = "subdomain_not_owned"
+	CodeSubdomainUnclaimed = "subdomain_not_claimed"
+	CodeClaimInCooldown    = "claim_in_cooldown"
+	CodeQuotaExceeded      = "quota_exceeded"
+	CodeRecordModerated    = "record_moderated"
+
+	CodeNotFound                = "not_found"
+	CodeRouteNotFound           = "route_not_found"
+	CodeUserNotFound            = "user_not_found"
+	CodeRecordNotFound          = "record_not_found"
+	CodeClaimNotFound           = "claim_not_found"
+	CodeWebhookNotFound         = "webhook_not_found"
+	CodeDeliveryNotFound        = "delivery_not_found"
+	CodeReportNotFound          = "report_not_found"
+	CodeWaitlistEntryNotFound   = "waitlist_entry_not_found"
+	CodeInvalidVerificationLink = "invalid_verification_link"
+
+	CodeConflict                = "conflict"
+	CodeSubdomainTaken          = "subdomain_taken"
+	CodeSubdomainTooSimilar     = "subdomain_too_similar"
+	CodeSubdomainReserved       = "subdomain_reserved"
+	CodeSubdomainReservedForYou = "subdomain_reserved_for_you"
+	CodeSubdomainAvailable      = "subdomain_available"
+	CodeSubdomainOwned          = "subdomain_owned"
+	CodeClaimLimitReached       = "claim_limit_reached"
+	CodeClaimHasRecords         = "claim_has_records"
+	CodeWebhookLimitReached     = "webhook_limit_reached"
+	CodeReportResolved          = "report_resolved"
+	CodeUserNotSuspended        = "user_not_suspended"
+
+	CodeRateLimited    = "rate_limited"
+	CodeTooManyStreams = "too_many_streams"
+
+	CodeInternalError      = "internal_error"
+	CodeUpstreamError      = "upstream_error"
+	CodeUnavailable        = "unavailable"
+	CodeShuttingDown       = "shutting_down"
+	CodeEmailNotConfigured = "email_not_configured"
+	CodeUpstreamTimeout    = "upstream_timeout"
+)
+
+// defaultCodes are used for errors that carry nothing but a status, such as
+// the *fiber.Error values Fiber itself returns.
+var defaultCodes = map[int]string{
+	fiber.StatusBadRequest:          CodeInvalidRequest,
+	fiber.StatusUnauthorized:        CodeUnauthenticated,
+	fiber.StatusForbidden:           CodeForbidden,
+	fiber.StatusNotFound:            CodeNotFound,
+	fiber.StatusConflict:            CodeConflict,
+	fiber.StatusTooManyRequests:     CodeRateLimited,
+	fiber.StatusInternalServerError: CodeInternalError,
+	fiber.StatusBadGateway:          CodeUpstreamError,
+	fiber.StatusServiceUnavailable:  CodeUnavailable,
+	fiber.StatusGatewayTimeout:      CodeUpstreamTimeout,
+}
@@ -0,0 +1,153 @@
+// Package problem defines the error envelope every API response uses: an RFC
+// 9457 problem details body with a stable machine-readable code.
+package problem
+
+import (
+	"btwarch/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// typePrefix turns a code into the problem's type URI.
+const typePrefix = "urn:btwarch:problem:"
+
+// LegacyLocal is the fiber.Ctx local set on requests made through a
+// deprecated unversioned alias. Their problems also carry the detail as
+// "error", the member clients of the old routes read.
+const LegacyLocal = "legacy_route"
+
+// Problem is an error that is answered with a problem details response.
+// Handlers return it instead of writing the error body themselves.
+type Problem struct {
+	Status int
+	Code   string
+	Detail string
+
+	// Extensions are additional members of the body, e.g. the fields that
+	// failed validation.
+	Extensions map[string]any
+
+	// cause is the underlying error. It is logged but never sent.
+	cause error
+}
+
+// New returns a problem with the given status, code and human-readable
+// detail.
+func New(status int, code, detail string) *Problem {
+	return &Problem{Status: status, Code: code, Detail: detail}
+}
+
+// Internal wraps an unexpected error. The client only learns that something
+// went wrong, or that an upstream timed out so that a retry may work.
+func Internal(err error) *Problem {
+	if utils.IsTimeout(err) {
+		return &Problem{
+			Status: fiber.StatusGatewayTimeout,
+			Code:   CodeUpstreamTimeout,
+			Detail: "timed out waiting for an upstream service",
+			cause:  err,
+		}
+	}
+	return &Problem{
+		Status: fiber.StatusInternalServerError,
+		Code:   CodeInternalError,
+		Detail: "an internal error occurred",
+		cause:  err,
+	}
+}
+
+// Upstream wraps an error returned by a Cloudflare call. Only the message of a
+// Cloudflare API error response is shown; anything else, such as a transport
+// error, gets a generic detail.
+func Upstream(err error) *Problem {
+	if utils.IsTimeout(err) {
+		return Internal(err)
+	}
+	detail, ok := utils.CloudflareErrorMessage(err)
+	if !ok {
+		detail = "the DNS provider did not accept the change"
+	}
+	return &Problem{
+		Status: fiber.StatusBadGateway,
+		Code:   CodeUpstreamError,
+		Detail: detail,
+		cause:  err,
+	}
+}
+
+// With adds an extension member to the body and returns p.
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.cause != nil {
+		return p.Code + ": " + p.cause.Error()
+	}
+	return p.Code + ": " + p.Detail
+}
+
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
+
+// From converts any error returned by a handler into a problem. Errors that
+// are neither problems nor *fiber.Error are treated as internal.
+func From(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		if fiberErr.Code >= fiber.StatusInternalServerError {
+			return Internal(err)
+		}
+		code := defaultCodes[fiberErr.Code]
+		if fiberErr.Code == fiber.StatusNotFound {
+			code = CodeRouteNotFound
+		}
+		if code == "" {
+			code = CodeInvalidRequest
+		}
+		return &Problem{Status: fiberErr.Code, Code: code, Detail: fiberErr.Message, cause: err}
+	}
+
+	return Internal(err)
+}
+
+// ErrorHandler writes the problem response for an error returned by a
+// handler. It is installed as the Fiber app's error handler.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	p := From(err)
+
+	body := fiber.Map{
+		"type":     typePrefix + p.Code,
+		"title":    http.StatusText(p.Status),
+		"status":   p.Status,
+		"code":     p.Code,
+		"detail":   p.Detail,
+		"instance": c.Path(),
+	}
+	if requestID, ok := c.Locals("request_id").(string); ok && requestID != "" {
+		body["request_id"] = requestID
+	}
+	for key, value := range p.Extensions {
+		body[key] = value
+	}
+	if legacy, _ := c.Locals(LegacyLocal).(bool); legacy {
+		body["error"] = p.Detail
+	}
+
+	return c.Status(p.Status).JSON(body, ContentType)
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"btwarch/config"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const postgresSweepInterval = 10 * time.Minute
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so that limits
+// are shared by every instance using the same database.
+type PostgresStore struct {
+	db        *sql.DB
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db, lastSweep: time.Now()}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit config.RateLimit) (Result, error) {
+	s.sweep(ctx)
+
+	// The refill and the take happen in a single statement so concurrent
+	// requests from different instances cannot both spend the last token.
+	query := `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, expires_at, updated_at)
+		VALUES ($1, $2::float8 - 1, TRUE, clock_timestamp() + $4::float8 * INTERVAL '1 second', clock_timestamp())
+		ON CONFLICT (key) DO UPDATE SET
+			allowed = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at) * $3::float8) >= 1,
+			tokens = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at) * $3::float8)
+				- CASE WHEN LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at) * $3::float8) >= 1 THEN 1 ELSE 0 END,
+			expires_at = clock_timestamp() + $4::float8 * INTERVAL '1 second',
+			updated_at = clock_timestamp()
+		RETURNING tokens, allowed
+	`
+
+	var tokens float64
+	var allowed bool
+	err := s.db.QueryRowContext(ctx, query, key, limit.Requests, refillRate(limit), limit.Period.Seconds()).Scan(&tokens, &allowed)
+	if err != nil {
+		return Result{}, fmt.Errorf("error taking rate limit token: %w", err)
+	}
+
+	return newResult(limit, tokens, allowed), nil
+}
+
+// sweep deletes buckets that have been idle long enough to be full again.
+func (s *PostgresStore) sweep(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastSweep) < postgresSweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE expires_at < NOW()`); err != nil {
+		slog.ErrorContext(ctx, "Error deleting expired rate limit buckets", "error", err)
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Result describes the state of a bucket after a request was counted.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed. It is zero
+	// when the request was allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. Take refills the bucket for key according to the
+// limit and takes one token from it if available.
+type Store interface {
+	Take(ctx context.Context, key string, limit config.RateLimit) (Result, error)
+}
+
+// NewStore returns the store selected by RATE_LIMIT_BACKEND.
+func NewStore(cfg *config.Config) (Store, error) {
+	switch cfg.RateLimitBackend {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "postgres":
+		return NewPostgresStore(database.DB), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend: %s", cfg.RateLimitBackend)
+	}
+}
+
+// refillRate is the number of tokens added per second.
+func refillRate(limit config.RateLimit) float64 {
+	return float64(limit.Requests) / limit.Period.Seconds()
+}
+
+func newResult(limit config.RateLimit, tokens float64, allowed bool) Result {
+	rate := refillRate(limit)
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsToDuration((float64(limit.Requests) - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+
+	return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
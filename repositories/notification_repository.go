@@ -0,0 +1,292 @@
+package repositories
+
+import (
+	"btwarch/database"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const preferencesColumns = `user_id, disabled_categories, email_override, email_override_verified_at,
+	pending_email, pending_email_expires_at, created_at, updated_at`
+
+const emailColumns = `id, user_id, to_address, event, subject, text_body, html_body, status, attempts,
+	next_attempt_at, last_error, sent_at, created_at, updated_at`
+
+// VerificationEmailEvent is the outbox event of emails confirming a new
+// notification address.
+const VerificationEmailEvent = "email.verify"
+
+// VerificationEmailStats summarizes the verification emails queued since a
+// point in time, by one user and to one address.
+type VerificationEmailStats struct {
+	UserCount        int
+	UserFirstAt      *time.Time
+	UserLastAt       *time.Time
+	RecipientCount   int
+	RecipientFirstAt *time.Time
+}
+
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository() *NotificationRepository {
+	return &NotificationRepository{db: database.DB}
+}
+
+func scanPreferences(row rowScanner) (*database.NotificationPreferences, error) {
+	prefs := &database.NotificationPreferences{}
+	err := row.Scan(
+		&prefs.UserId, pq.Array(&prefs.DisabledCategories), &prefs.EmailOverride, &prefs.EmailOverrideVerifiedAt,
+		&prefs.PendingEmail, &prefs.PendingEmailExpiresAt, &prefs.CreatedAt, &prefs.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+func scanEmail(row rowScanner) (*database.OutgoingEmail, error) {
+	email := &database.OutgoingEmail{}
+	err := row.Scan(
+		&email.ID, &email.UserId, &email.ToAddress, &email.Event, &email.Subject, &email.TextBody,
+		&email.HTMLBody, &email.Status, &email.Attempts, &email.NextAttemptAt, &email.LastError,
+		&email.SentAt, &email.CreatedAt, &email.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return email, nil
+}
+
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID uuid.UUID) (*database.NotificationPreferences, error) {
+	query := `SELECT ` + preferencesColumns + ` FROM notification_preferences WHERE user_id = $1`
+
+	prefs, err := scanPreferences(r.db.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting notification preferences: %w", err)
+	}
+
+	return prefs, nil
+}
+
+func (r *NotificationRepository) SetDisabledCategories(ctx context.Context, userID uuid.UUID, categories []string) (*database.NotificationPreferences, error) {
+	query := `
+		INSERT INTO notification_preferences (user_id, disabled_categories)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET disabled_categories = EXCLUDED.disabled_categories, updated_at = $3
+		RETURNING ` + preferencesColumns
+
+	prefs, err := scanPreferences(r.db.QueryRowContext(ctx, query, userID, pq.Array(categories), time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("error updating notification preferences: %w", err)
+	}
+
+	return prefs, nil
+}
+
+// SetPendingEmail stores an override address that becomes active once the
+// token sent to it is confirmed. The current override stays in use until then.
+func (r *NotificationRepository) SetPendingEmail(ctx context.Context, userID uuid.UUID, email, tokenHash string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO notification_preferences (user_id, pending_email, pending_email_token_hash, pending_email_expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			pending_email = EXCLUDED.pending_email,
+			pending_email_token_hash = EXCLUDED.pending_email_token_hash,
+			pending_email_expires_at = EXCLUDED.pending_email_expires_at,
+			updated_at = $5
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, email, tokenHash, expiresAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("error setting pending email: %w", err)
+	}
+	return nil
+}
+
+// VerifyPendingEmail makes the pending address with the given token the
+// user's override. It returns nil if the token is unknown or expired.
+func (r *NotificationRepository) VerifyPendingEmail(ctx context.Context, tokenHash string) (*database.NotificationPreferences, error) {
+	query := `
+		UPDATE notification_preferences
+		SET email_override = pending_email, email_override_verified_at = $2,
+			pending_email = NULL, pending_email_token_hash = NULL, pending_email_expires_at = NULL,
+			updated_at = $2
+		WHERE pending_email_token_hash = $1 AND pending_email_expires_at > $2
+		RETURNING ` + preferencesColumns
+
+	prefs, err := scanPreferences(r.db.QueryRowContext(ctx, query, tokenHash, time.Now()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error verifying email: %w", err)
+	}
+
+	return prefs, nil
+}
+
+func (r *NotificationRepository) ClearEmailOverride(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE notification_preferences
+		SET email_override = NULL, email_override_verified_at = NULL,
+			pending_email = NULL, pending_email_token_hash = NULL, pending_email_expires_at = NULL,
+			updated_at = $2
+		WHERE user_id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("error clearing email override: %w", err)
+	}
+	return nil
+}
+
+// TouchDevice records a login from the device with the given token and
+// reports whether the device was new for the user.
+func (r *NotificationRepository) TouchDevice(ctx context.Context, userID uuid.UUID, tokenHash, userAgent, ipAddress string) (bool, error) {
+	query := `
+		INSERT INTO user_devices (user_id, token_hash, user_agent, ip_address)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, token_hash) DO UPDATE SET
+			user_agent = EXCLUDED.user_agent, ip_address = EXCLUDED.ip_address, last_seen_at = $5
+		RETURNING (xmax = 0)
+	`
+
+	var inserted bool
+	if err := r.db.QueryRowContext(ctx, query, userID, tokenHash, userAgent, ipAddress, time.Now()).Scan(&inserted); err != nil {
+		return false, fmt.Errorf("error recording device: %w", err)
+	}
+
+	return inserted, nil
+}
+
+func (r *NotificationRepository) CountDevices(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_devices WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting devices: %w", err)
+	}
+	return count, nil
+}
+
+func (r *NotificationRepository) QueueEmail(ctx context.Context, userID *uuid.UUID, toAddress, event, subject, textBody, htmlBody string) error {
+	query := `
+		INSERT INTO email_outbox (user_id, to_address, event, subject, text_body, html_body)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, toAddress, event, subject, textBody, htmlBody)
+	if err != nil {
+		return fmt.Errorf("error queueing email: %w", err)
+	}
+	return nil
+}
+
+// GetVerificationEmailStats counts the verification emails queued since the
+// given time by the user and, from anyone, to the address.
+func (r *NotificationRepository) GetVerificationEmailStats(ctx context.Context, userID uuid.UUID, address string, since time.Time) (*VerificationEmailStats, error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE user_id = $2),
+			MIN(created_at) FILTER (WHERE user_id = $2),
+			MAX(created_at) FILTER (WHERE user_id = $2),
+			COUNT(*) FILTER (WHERE LOWER(to_address) = LOWER($3)),
+			MIN(created_at) FILTER (WHERE LOWER(to_address) = LOWER($3))
+		FROM email_outbox
+		WHERE event = $1 AND created_at >= $4 AND (user_id = $2 OR LOWER(to_address) = LOWER($3))
+	`
+
+	stats := &VerificationEmailStats{}
+	var userFirst, userLast, recipientFirst sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, VerificationEmailEvent, userID, address, since).Scan(
+		&stats.UserCount, &userFirst, &userLast, &stats.RecipientCount, &recipientFirst,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error counting verification emails: %w", err)
+	}
+	if userFirst.Valid {
+		stats.UserFirstAt = &userFirst.Time
+	}
+	if userLast.Valid {
+		stats.UserLastAt = &userLast.Time
+	}
+	if recipientFirst.Valid {
+		stats.RecipientFirstAt = &recipientFirst.Time
+	}
+	return stats, nil
+}
+
+// ClaimDueEmails takes up to limit pending emails whose next attempt is due
+// and pushes their next attempt back by lease, so that other instances do not
+// send them at the same time.
+func (r *NotificationRepository) ClaimDueEmails(ctx context.Context, limit int, lease time.Duration) ([]*database.OutgoingEmail, error) {
+	query := `
+		UPDATE email_outbox
+		SET next_attempt_at = NOW() + $2::float8 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + emailColumns
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error claiming emails: %w", err)
+	}
+	defer rows.Close()
+
+	var emails []*database.OutgoingEmail
+	for rows.Next() {
+		email, err := scanEmail(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning email: %w", err)
+		}
+		emails = append(emails, email)
+	}
+
+	return emails, nil
+}
+
+func (r *NotificationRepository) MarkEmailSent(ctx context.Context, emailID uuid.UUID) error {
+	query := `
+		UPDATE email_outbox
+		SET status = 'sent', attempts = attempts + 1, last_error = NULL, sent_at = $1, updated_at = $1
+		WHERE id = $2
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now(), emailID)
+	if err != nil {
+		return fmt.Errorf("error updating email: %w", err)
+	}
+	return nil
+}
+
+// MarkEmailFailed records a failed attempt. With a nil nextAttemptAt the email
+// is given up on; otherwise it is retried at that time.
+func (r *NotificationRepository) MarkEmailFailed(ctx context.Context, emailID uuid.UUID, errMessage string, nextAttemptAt *time.Time) error {
+	query := `
+		UPDATE email_outbox
+		SET status = CASE WHEN $2::timestamp IS NULL THEN 'failed' ELSE 'pending' END,
+			attempts = attempts + 1, last_error = $1,
+			next_attempt_at = COALESCE($2::timestamp, next_attempt_at), updated_at = $3
+		WHERE id = $4
+	`
+
+	_, err := r.db.ExecContext(ctx, query, errMessage, nextAttemptAt, time.Now(), emailID)
+	if err != nil {
+		return fmt.Errorf("error updating email: %w", err)
+	}
+	return nil
+}
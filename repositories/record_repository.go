@@ -4,53 +4,89 @@ import (
 	"btwarch/config"
 	"btwarch/database"
 	"btwarch/services"
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/cloudflare/cloudflare-go/v4/dns"
 	"github.com/google/uuid"
 )
 
+const recordColumns = `id, user_id, record_name, record_type, record_value, ttl, is_active, cloudflare_record_id, suspended, moderated_at, created_at, updated_at`
+
 type RecordRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	config *config.Config
+}
+
+func NewRecordRepository(config *config.Config) *RecordRepository {
+	return &RecordRepository{db: database.DB, config: config}
+}
+
+func scanRecord(row rowScanner) (*database.Record, error) {
+	record := &database.Record{}
+	err := row.Scan(
+		&record.ID, &record.UserId, &record.RecordName,
+		&record.RecordType, &record.RecordValue, &record.TTL,
+		&record.IsActive, &record.CloudflareRecordID, &record.Suspended, &record.ModeratedAt, &record.CreatedAt, &record.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
 }
 
-func NewRecordRepository() *RecordRepository {
-	return &RecordRepository{db: database.DB}
+func (r *RecordRepository) queryRecords(ctx context.Context, query string, args ...any) ([]*database.Record, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*database.Record
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning record: %w", err)
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
 }
 
 func (r *RecordRepository) getCloudflareService() (*services.CloudflareService, error) {
-	cfg := config.LoadConfig()
-	return services.NewCloudflareService(cfg.CloudFlareApiToken)
+	return services.NewCloudflareService(r.config.CloudFlareApiToken.Value(), r.config.CloudFlareZoneId, r.config.CloudflareTimeout)
 }
 
-func (r *RecordRepository) CreateOnCloudflare(record database.Record) (*dns.RecordResponse, error) {
+func (r *RecordRepository) CreateOnCloudflare(ctx context.Context, record database.Record) (*dns.RecordResponse, error) {
 	cf, err := r.getCloudflareService()
 	if err != nil {
 		return nil, err
 	}
 	switch record.RecordType {
 	case "A":
-		resp, err := cf.AddARecord(record.RecordName, record.RecordValue)
+		resp, err := cf.AddARecord(ctx, record.RecordName, record.RecordValue)
 		if err != nil {
 			return nil, err
 		}
 		return resp, nil
 	case "AAAA":
-		resp, err := cf.AddAAAARecord(record.RecordName, record.RecordValue)
+		resp, err := cf.AddAAAARecord(ctx, record.RecordName, record.RecordValue)
 		if err != nil {
 			return nil, err
 		}
 		return resp, nil
 	case "TXT":
-		resp, err := cf.AddTXTRecord(record.RecordName, record.RecordValue)
+		resp, err := cf.AddTXTRecord(ctx, record.RecordName, record.RecordValue)
 		if err != nil {
 			return nil, err
 		}
 		return resp, nil
 	case "CNAME":
-		resp, err := cf.AddCNAMERecord(record.RecordName, record.RecordValue)
+		resp, err := cf.AddCNAMERecord(ctx, record.RecordName, record.RecordValue)
 		if err != nil {
 			return nil, err
 		}
@@ -60,32 +96,32 @@ func (r *RecordRepository) CreateOnCloudflare(record database.Record) (*dns.Reco
 	}
 }
 
-func (r *RecordRepository) UpdateOnCloudflare(recordID string, record database.Record) (*dns.RecordResponse, error) {
+func (r *RecordRepository) UpdateOnCloudflare(ctx context.Context, recordID string, record database.Record) (*dns.RecordResponse, error) {
 	cf, err := r.getCloudflareService()
 	if err != nil {
 		return nil, err
 	}
 	switch record.RecordType {
 	case "A":
-		resp, err := cf.UpdateARecord(recordID, record.RecordName, record.RecordValue)
+		resp, err := cf.UpdateARecord(ctx, recordID, record.RecordName, record.RecordValue)
 		if err != nil {
 			return nil, err
 		}
 		return resp, nil
 	case "AAAA":
-		resp, err := cf.UpdateAAAARecord(recordID, record.RecordName, record.RecordValue)
+		resp, err := cf.UpdateAAAARecord(ctx, recordID, record.RecordName, record.RecordValue)
 		if err != nil {
 			return nil, err
 		}
 		return resp, nil
 	case "CNAME":
-		resp, err := cf.UpdateCNAMERecord(recordID, record.RecordName, record.RecordValue)
+		resp, err := cf.UpdateCNAMERecord(ctx, recordID, record.RecordName, record.RecordValue)
 		if err != nil {
 			return nil, err
 		}
 		return resp, nil
 	case "TXT":
-		resp, err := cf.UpdateTXTRecord(recordID, record.RecordName, record.RecordValue)
+		resp, err := cf.UpdateTXTRecord(ctx, recordID, record.RecordName, record.RecordValue)
 		if err != nil {
 			return nil, err
 		}
@@ -95,225 +131,513 @@ func (r *RecordRepository) UpdateOnCloudflare(recordID string, record database.R
 	}
 }
 
-func (r *RecordRepository) CreateCloudflareRecord(record database.Record) (*dns.RecordResponse, error) {
-	return r.CreateOnCloudflare(record)
+func (r *RecordRepository) CreateCloudflareRecord(ctx context.Context, record database.Record) (*dns.RecordResponse, error) {
+	return r.CreateOnCloudflare(ctx, record)
 }
 
-func (r *RecordRepository) DeleteCloudflareRecord(recordID string) error {
+func (r *RecordRepository) DeleteCloudflareRecord(ctx context.Context, recordID string) error {
 	cf, err := r.getCloudflareService()
 	if err != nil {
 		return err
 	}
-	_, err = cf.DeleteRecordByID(recordID)
+	_, err = cf.DeleteRecordByID(ctx, recordID)
 	return err
 }
 
-func (r *RecordRepository) UpdateCloudflareIDByNameAndType(recordName string, recordType string, cfID string) error {
+func (r *RecordRepository) UpdateCloudflareIDByNameAndType(ctx context.Context, recordName string, recordType string, cfID string) error {
 	query := `
 		UPDATE records
 		SET cloudflare_record_id = $1, updated_at = $2
 		WHERE record_name = $3 AND record_type = $4
 	`
-	_, err := r.db.Exec(query, cfID, time.Now(), recordName, recordType)
+	_, err := r.db.ExecContext(ctx, query, cfID, time.Now(), recordName, recordType)
 	return err
 }
 
-func (r *RecordRepository) CreateRecord(userID uuid.UUID, domainName, recordType, recordValue string, ttl int, isActive bool) (*database.Record, error) {
-	var cloudflareID *string
-	if isActive {
-		newRecord := database.Record{
-			UserId:      userID,
-			RecordName:  domainName,
-			RecordType:  recordType,
-			RecordValue: recordValue,
-			TTL:         ttl,
-			IsActive:    true,
-		}
-		id, err := r.CreateCloudflareRecord(newRecord)
-		if err != nil {
-			return nil, err
-		}
-		cloudflareID = &id.ID
-	}
+// RecordQuota limits the records under a single claimed subdomain. A zero
+// limit means unlimited.
+type RecordQuota struct {
+	MaxRecords    int
+	MaxTXTRecords int
+}
+
+// RecordUsage counts the records under a claimed subdomain.
+type RecordUsage struct {
+	Records    int
+	TXTRecords int
+}
+
+// QuotaExceededError is returned by CreateRecord and UpdateRecord when the
+// claim has no room for another record.
+type QuotaExceededError struct {
+	Quota string
+	Limit int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s quota of %d reached for this subdomain", e.Quota, e.Limit)
+}
+
+// CloudflareSyncError is returned by CreateRecord when Cloudflare did not
+// accept the new record, as opposed to a failure of the database.
+type CloudflareSyncError struct {
+	Err error
+}
+
+func (e *CloudflareSyncError) Error() string {
+	return "error creating record on Cloudflare: " + e.Err.Error()
+}
+
+func (e *CloudflareSyncError) Unwrap() error {
+	return e.Err
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
 
+func getRecordUsage(ctx context.Context, db queryRower, fullSubdomain string) (*RecordUsage, error) {
 	query := `
-		INSERT INTO records (user_id, record_name, record_type, record_value, ttl, is_active, cloudflare_record_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, user_id, record_name, record_type, record_value, ttl, is_active, cloudflare_record_id, created_at, updated_at
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE record_type = 'TXT')
+		FROM records
+		WHERE record_name = $1 OR record_name LIKE '%.' || $1
 	`
 
-	record := &database.Record{}
-	err := r.db.QueryRow(
-		query,
-		userID, domainName, recordType, recordValue, ttl, isActive, cloudflareID,
-	).Scan(
-		&record.ID, &record.UserId, &record.RecordName,
-		&record.RecordType, &record.RecordValue, &record.TTL,
-		&record.IsActive, &record.CloudflareRecordID, &record.CreatedAt, &record.UpdatedAt,
-	)
+	usage := &RecordUsage{}
+	if err := db.QueryRowContext(ctx, query, fullSubdomain).Scan(&usage.Records, &usage.TXTRecords); err != nil {
+		return nil, fmt.Errorf("error counting records: %w", err)
+	}
+	return usage, nil
+}
 
+func (r *RecordRepository) GetRecordUsage(ctx context.Context, fullSubdomain string) (*RecordUsage, error) {
+	return getRecordUsage(ctx, r.db, fullSubdomain)
+}
+
+// lockClaim locks the claim row for the rest of the transaction, so that
+// quota checks on the same claim run one at a time.
+func lockClaim(ctx context.Context, tx *sql.Tx, claimID uuid.UUID) error {
+	var lockedID uuid.UUID
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM subdomain_claims WHERE id = $1 FOR UPDATE`, claimID).Scan(&lockedID); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("subdomain claim not found")
+		}
+		return fmt.Errorf("error locking subdomain claim: %w", err)
+	}
+	return nil
+}
+
+// CreateRecord creates a record under the given claim. The claim row is locked
+// while the quota is checked and the record inserted, so concurrent requests
+// cannot exceed it. An active record is published to Cloudflare after the
+// lock is released; if that fails the row is removed again.
+func (r *RecordRepository) CreateRecord(ctx context.Context, claimID uuid.UUID, fullSubdomain string, quota RecordQuota, userID uuid.UUID, domainName, recordType, recordValue string, ttl int, isActive bool) (*database.Record, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error creating record: %v", err)
+		return nil, fmt.Errorf("error starting transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return record, nil
-}
+	if err := lockClaim(ctx, tx, claimID); err != nil {
+		return nil, err
+	}
+
+	usage, err := getRecordUsage(ctx, tx, fullSubdomain)
+	if err != nil {
+		return nil, err
+	}
+	if quota.MaxRecords > 0 && usage.Records >= quota.MaxRecords {
+		return nil, &QuotaExceededError{Quota: "records", Limit: quota.MaxRecords}
+	}
+	if recordType == "TXT" && quota.MaxTXTRecords > 0 && usage.TXTRecords >= quota.MaxTXTRecords {
+		return nil, &QuotaExceededError{Quota: "txt_records", Limit: quota.MaxTXTRecords}
+	}
 
-func (r *RecordRepository) GetRecordsByUserID(userID uuid.UUID) ([]*database.Record, error) {
 	query := `
-		SELECT id, user_id, record_name, record_type, record_value, ttl, is_active, cloudflare_record_id, created_at, updated_at
-		FROM records WHERE user_id = $1 ORDER BY created_at DESC
-	`
+		INSERT INTO records (user_id, record_name, record_type, record_value, ttl, is_active)
+		VALUES ($1, $2, $3, $4, $5, FALSE)
+		RETURNING ` + recordColumns
 
-	rows, err := r.db.Query(query, userID)
+	record, err := scanRecord(tx.QueryRowContext(ctx,
+		query,
+		userID, domainName, recordType, recordValue, ttl,
+	))
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error getting records: %v", err)
+		return nil, fmt.Errorf("error creating record: %w", err)
 	}
-	defer rows.Close()
 
-	var records []*database.Record
-	for rows.Next() {
-		record := &database.Record{}
-		err := rows.Scan(
-			&record.ID, &record.UserId, &record.RecordName,
-			&record.RecordType, &record.RecordValue, &record.TTL,
-			&record.IsActive, &record.CloudflareRecordID, &record.CreatedAt, &record.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning record: %v", err)
+	if !isActive {
+		return record, nil
+	}
+
+	cfRecord, err := r.CreateCloudflareRecord(ctx, *record)
+	if err != nil {
+		r.removeUnpublishedRecord(ctx, record.ID)
+		return nil, &CloudflareSyncError{Err: err}
+	}
+
+	query = `
+		UPDATE records
+		SET is_active = TRUE, cloudflare_record_id = $1, updated_at = $2
+		WHERE id = $3
+		RETURNING ` + recordColumns
+
+	published, err := scanRecord(r.db.QueryRowContext(ctx, query, cfRecord.ID, time.Now(), record.ID))
+	if err != nil {
+		if cfErr := r.DeleteCloudflareRecord(ctx, cfRecord.ID); cfErr != nil {
+			slog.Error("Error removing orphaned Cloudflare record", "cloudflare_record_id", cfRecord.ID, "error", cfErr)
 		}
-		records = append(records, record)
+		r.removeUnpublishedRecord(ctx, record.ID)
+		return nil, fmt.Errorf("error activating record: %w", err)
 	}
 
-	return records, nil
+	return published, nil
 }
 
-func (r *RecordRepository) GetRecordByID(recordID uuid.UUID) (*database.Record, error) {
-	query := `
-		SELECT id, user_id, record_name, record_type, record_value, ttl, is_active, cloudflare_record_id, created_at, updated_at
-		FROM records WHERE id = $1
-	`
+// removeUnpublishedRecord deletes a row whose Cloudflare record could not be
+// created.
+func (r *RecordRepository) removeUnpublishedRecord(ctx context.Context, recordID uuid.UUID) {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM records WHERE id = $1`, recordID); err != nil {
+		slog.Error("Error removing unpublished record", "record_id", recordID, "error", err)
+	}
+}
 
-	record := &database.Record{}
-	err := r.db.QueryRow(query, recordID).Scan(
-		&record.ID, &record.UserId, &record.RecordName,
-		&record.RecordType, &record.RecordValue, &record.TTL,
-		&record.IsActive, &record.CloudflareRecordID, &record.CreatedAt, &record.UpdatedAt,
-	)
+func (r *RecordRepository) GetRecordsByUserID(ctx context.Context, userID uuid.UUID) ([]*database.Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM records WHERE user_id = $1 ORDER BY created_at DESC`
+	return r.queryRecords(ctx, query, userID)
+}
+
+func (r *RecordRepository) GetRecordByID(ctx context.Context, recordID uuid.UUID) (*database.Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM records WHERE id = $1`
 
+	record, err := scanRecord(r.db.QueryRowContext(ctx, query, recordID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("error getting record: %v", err)
+		return nil, fmt.Errorf("error getting record: %w", err)
 	}
 
 	return record, nil
 }
 
-func (r *RecordRepository) GetRecordByName(domainName string) (*database.Record, error) {
-	query := `
-		SELECT id, user_id, record_name, record_type, record_value, ttl, is_active, cloudflare_record_id, created_at, updated_at
-		FROM records WHERE record_name = $1
-	`
+func (r *RecordRepository) GetRecordByName(ctx context.Context, domainName string) (*database.Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM records WHERE record_name = $1`
 
-	record := &database.Record{}
-	err := r.db.QueryRow(query, domainName).Scan(
-		&record.ID, &record.UserId, &record.RecordName,
-		&record.RecordType, &record.RecordValue, &record.TTL,
-		&record.IsActive, &record.CloudflareRecordID, &record.CreatedAt, &record.UpdatedAt,
-	)
+	record, err := scanRecord(r.db.QueryRowContext(ctx, query, domainName))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting record: %w", err)
+	}
+
+	return record, nil
+}
+
+func (r *RecordRepository) GetRecordByNameAndType(ctx context.Context, recordName string, recordType string) (*database.Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM records WHERE record_name = $1 AND record_type = $2`
 
+	record, err := scanRecord(r.db.QueryRowContext(ctx, query, recordName, recordType))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("error getting record: %v", err)
+		return nil, fmt.Errorf("error getting record: %w", err)
 	}
 
 	return record, nil
 }
 
-func (r *RecordRepository) GetRecordByNameAndType(recordName string, recordType string) (*database.Record, error) {
+// GetRecordsBySubdomain returns the records for a full subdomain name and all
+// of its sub-labels.
+func (r *RecordRepository) GetRecordsBySubdomain(ctx context.Context, fullSubdomain string) ([]*database.Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM records WHERE record_name = $1 OR record_name LIKE '%.' || $1 ORDER BY created_at DESC`
+	return r.queryRecords(ctx, query, fullSubdomain)
+}
+
+// SearchRecords returns a page of records across all users whose name or
+// value matches the search term, or whose owner ID equals it, with the total
+// match count.
+func (r *RecordRepository) SearchRecords(ctx context.Context, search string, limit, offset int) ([]*database.Record, int, error) {
+	where := `
+		WHERE $1 = '' OR record_name ILIKE $2 OR record_value ILIKE $2 OR user_id::text = $1
+	`
+	pattern := "%" + escapeLike(search) + "%"
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM records`+where, search, pattern).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting records: %w", err)
+	}
+
+	query := `SELECT ` + recordColumns + ` FROM records` + where + ` ORDER BY created_at DESC LIMIT $3 OFFSET $4`
+	records, err := r.queryRecords(ctx, query, search, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if records == nil {
+		records = []*database.Record{}
+	}
+
+	return records, total, nil
+}
+
+// DeactivateRecord removes the record from Cloudflare and marks it inactive,
+// keeping the row so it can be restored later.
+func (r *RecordRepository) DeactivateRecord(ctx context.Context, recordID uuid.UUID) error {
+	return r.deactivateRecord(ctx, recordID, false, false)
+}
+
+// SuspendRecord deactivates the record and flags it as taken down by a user
+// suspension, so that lifting the suspension can bring it back.
+func (r *RecordRepository) SuspendRecord(ctx context.Context, recordID uuid.UUID) error {
+	return r.deactivateRecord(ctx, recordID, true, false)
+}
+
+// ModerateRecord deactivates the record and locks it, so that its owner
+// cannot enable it again until an administrator calls ClearModeration.
+func (r *RecordRepository) ModerateRecord(ctx context.Context, recordID uuid.UUID) error {
+	return r.deactivateRecord(ctx, recordID, false, true)
+}
+
+// ClearModeration lifts the lock set by ModerateRecord. The record stays
+// disabled until its owner enables it.
+func (r *RecordRepository) ClearModeration(ctx context.Context, recordID uuid.UUID) error {
+	query := `UPDATE records SET moderated_at = NULL, updated_at = $1 WHERE id = $2`
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), recordID); err != nil {
+		return fmt.Errorf("error clearing record moderation: %w", err)
+	}
+	return nil
+}
+
+// ClearModerationBySubdomain lifts the locks of every record under the
+// subdomain.
+func (r *RecordRepository) ClearModerationBySubdomain(ctx context.Context, fullSubdomain string) error {
 	query := `
-		SELECT id, user_id, record_name, record_type, record_value, ttl, is_active, cloudflare_record_id, created_at, updated_at
-		FROM records WHERE record_name = $1 AND record_type = $2
+		UPDATE records SET moderated_at = NULL, updated_at = $1
+		WHERE moderated_at IS NOT NULL AND (record_name = $2 OR record_name LIKE '%.' || $2)
 	`
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), fullSubdomain); err != nil {
+		return fmt.Errorf("error clearing record moderation: %w", err)
+	}
+	return nil
+}
 
-	record := &database.Record{}
-	err := r.db.QueryRow(query, recordName, recordType).Scan(
-		&record.ID, &record.UserId, &record.RecordName,
-		&record.RecordType, &record.RecordValue, &record.TTL,
-		&record.IsActive, &record.CloudflareRecordID, &record.CreatedAt, &record.UpdatedAt,
-	)
+func (r *RecordRepository) deactivateRecord(ctx context.Context, recordID uuid.UUID, suspended, moderated bool) error {
+	rec, err := r.GetRecordByID(ctx, recordID)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return fmt.Errorf("record not found")
+	}
+
+	if rec.CloudflareRecordID != nil && *rec.CloudflareRecordID != "" {
+		if err := r.DeleteCloudflareRecord(ctx, *rec.CloudflareRecordID); err != nil {
+			return fmt.Errorf("cloudflare delete failed: %w", err)
+		}
+	}
 
+	query := `
+		UPDATE records
+		SET is_active = FALSE, cloudflare_record_id = NULL, suspended = $1,
+			moderated_at = CASE WHEN $2 THEN $3 ELSE moderated_at END, updated_at = $3
+		WHERE id = $4
+	`
+	_, err = r.db.ExecContext(ctx, query, suspended, moderated, time.Now(), recordID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+		return fmt.Errorf("error deactivating record: %w", err)
+	}
+	return nil
+}
+
+func (r *RecordRepository) GetSuspendedRecordsByUserID(ctx context.Context, userID uuid.UUID) ([]*database.Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM records WHERE user_id = $1 AND suspended ORDER BY created_at`
+	return r.queryRecords(ctx, query, userID)
+}
+
+// RestoreRecord recreates a suspended record at Cloudflare and marks it active
+// again. A record its owner already enabled, or one an administrator locked,
+// only has its suspended flag cleared.
+func (r *RecordRepository) RestoreRecord(ctx context.Context, recordID uuid.UUID) error {
+	rec, err := r.GetRecordByID(ctx, recordID)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return fmt.Errorf("record not found")
+	}
+
+	if rec.IsActive || rec.CloudflareRecordID != nil || rec.ModeratedAt != nil {
+		return r.clearSuspended(ctx, recordID)
+	}
+
+	resp, err := r.CreateOnCloudflare(ctx, *rec)
+	if err != nil {
+		return fmt.Errorf("cloudflare create failed: %w", err)
+	}
+
+	// The owner may have enabled the record while it was being created, in
+	// which case theirs is the Cloudflare record to keep.
+	query := `
+		UPDATE records
+		SET is_active = TRUE, cloudflare_record_id = $1, suspended = FALSE, updated_at = $2
+		WHERE id = $3 AND suspended AND cloudflare_record_id IS NULL
+	`
+	res, err := r.db.ExecContext(ctx, query, resp.ID, time.Now(), recordID)
+	if err != nil {
+		return fmt.Errorf("error restoring record: %w", err)
+	}
+	restored, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error restoring record: %w", err)
+	}
+	if restored == 0 {
+		if err := r.DeleteCloudflareRecord(ctx, resp.ID); err != nil {
+			return fmt.Errorf("error deleting duplicate cloudflare record: %w", err)
 		}
-		return nil, fmt.Errorf("error getting record: %v", err)
+		return r.clearSuspended(ctx, recordID)
 	}
+	return nil
+}
 
-	return record, nil
+func (r *RecordRepository) clearSuspended(ctx context.Context, recordID uuid.UUID) error {
+	query := `UPDATE records SET suspended = FALSE, updated_at = $1 WHERE id = $2`
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), recordID); err != nil {
+		return fmt.Errorf("error clearing suspended flag: %w", err)
+	}
+	return nil
 }
 
-func (r *RecordRepository) RecordExists(domainName string) (bool, error) {
+// GetUsersWithUnrestoredRecords returns the users who are no longer suspended
+// but still have records flagged as suspended because restoring them failed.
+func (r *RecordRepository) GetUsersWithUnrestoredRecords(ctx context.Context) ([]uuid.UUID, error) {
+	query := `
+		SELECT DISTINCT records.user_id FROM records
+		JOIN users ON users.id = records.user_id
+		WHERE records.suspended AND users.suspended_at IS NULL
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting users with unrestored records: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("error scanning user id: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// ClearSuspendedRecords drops the suspension flag from the user's records
+// without restoring them, leaving them inactive.
+func (r *RecordRepository) ClearSuspendedRecords(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE records
+		SET suspended = FALSE, updated_at = $1
+		WHERE user_id = $2 AND suspended
+	`
+	_, err := r.db.ExecContext(ctx, query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("error clearing suspended records: %w", err)
+	}
+	return nil
+}
+
+func (r *RecordRepository) RecordExists(ctx context.Context, domainName string) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM records WHERE record_name = $1)`
 	var exists bool
-	err := r.db.QueryRow(query, domainName).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, query, domainName).Scan(&exists)
 	if err != nil {
-		return false, fmt.Errorf("error checking record: %v", err)
+		return false, fmt.Errorf("error checking record: %w", err)
 	}
 	return exists, nil
 }
 
-func (r *RecordRepository) UpdateRecord(recordID uuid.UUID, recordName string, recordType string, recordValue string, ttl int) error {
+// UpdateRecord changes a record under the given claim. The claim row is
+// locked while the TXT quota is checked for records that become TXT records,
+// as in CreateRecord.
+func (r *RecordRepository) UpdateRecord(ctx context.Context, claimID uuid.UUID, fullSubdomain string, quota RecordQuota, recordID uuid.UUID, recordName string, recordType string, recordValue string, ttl int) error {
 	if recordType != "CNAME" && recordType != "A" && recordType != "AAAA" && recordType != "TXT" {
 		return fmt.Errorf("invalid record type: %s", recordType)
 	}
 
-	existingRecord, err := r.GetRecordByID(recordID)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error getting record: %v", err)
+		return fmt.Errorf("error starting transaction: %w", err)
 	}
-	if existingRecord == nil {
-		return fmt.Errorf("record not found")
+	defer tx.Rollback()
+
+	if err := lockClaim(ctx, tx, claimID); err != nil {
+		return err
+	}
+
+	existingRecord, err := scanRecord(tx.QueryRowContext(ctx, `SELECT `+recordColumns+` FROM records WHERE id = $1`, recordID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("record not found")
+		}
+		return fmt.Errorf("error getting record: %w", err)
 	}
 
 	if recordType != "TXT" && recordName != existingRecord.RecordName {
 		return fmt.Errorf("cannot change record name for %s records", recordType)
 	}
 
+	if recordType == "TXT" && existingRecord.RecordType != "TXT" && quota.MaxTXTRecords > 0 {
+		usage, err := getRecordUsage(ctx, tx, fullSubdomain)
+		if err != nil {
+			return err
+		}
+		if usage.TXTRecords >= quota.MaxTXTRecords {
+			return &QuotaExceededError{Quota: "txt_records", Limit: quota.MaxTXTRecords}
+		}
+	}
+
 	query := `
 		UPDATE records 
 		SET record_name = $1, record_type = $2, record_value = $3, ttl = $4, updated_at = $5
 		WHERE id = $6
 	`
 
-	_, err = r.db.Exec(query, recordName, recordType, recordValue, ttl, time.Now(), recordID)
+	_, err = tx.ExecContext(ctx, query, recordName, recordType, recordValue, ttl, time.Now(), recordID)
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
-		return fmt.Errorf("error updating record: %v", err)
+		return fmt.Errorf("error updating record: %w", err)
 	}
 	return nil
 }
 
-func (r *RecordRepository) UpdateRecordStatus(recordID uuid.UUID, isActive bool) error {
+// UpdateRecordStatus enables or disables the record. Enabling it also clears
+// the suspended flag, so that lifting a suspension does not restore it a
+// second time.
+func (r *RecordRepository) UpdateRecordStatus(ctx context.Context, recordID uuid.UUID, isActive bool) error {
 	query := `
 		UPDATE records 
-		SET is_active = $1, updated_at = $2
+		SET is_active = $1, suspended = suspended AND NOT $1, updated_at = $2
 		WHERE id = $3
 	`
 
-	_, err := r.db.Exec(query, isActive, time.Now(), recordID)
+	_, err := r.db.ExecContext(ctx, query, isActive, time.Now(), recordID)
 	if err != nil {
-		return fmt.Errorf("error updating record status: %v", err)
+		return fmt.Errorf("error updating record status: %w", err)
 	}
 
 	return nil
 }
 
-func (r *RecordRepository) DeleteRecord(recordID uuid.UUID) error {
-	rec, err := r.GetRecordByID(recordID)
+func (r *RecordRepository) DeleteRecord(ctx context.Context, recordID uuid.UUID) error {
+	rec, err := r.GetRecordByID(ctx, recordID)
 	if err != nil {
 		return err
 	}
@@ -325,33 +649,33 @@ func (r *RecordRepository) DeleteRecord(recordID uuid.UUID) error {
 		if err != nil {
 			return err
 		}
-		if _, err := cf.DeleteRecordByID(*rec.CloudflareRecordID); err != nil {
+		if _, err := cf.DeleteRecordByID(ctx, *rec.CloudflareRecordID); err != nil {
 			return fmt.Errorf("cloudflare delete failed: %w", err)
 		}
 	}
 
 	query := `DELETE FROM records WHERE id = $1`
-	_, err = r.db.Exec(query, recordID)
+	_, err = r.db.ExecContext(ctx, query, recordID)
 	if err != nil {
-		return fmt.Errorf("error deleting record: %v", err)
+		return fmt.Errorf("error deleting record: %w", err)
 	}
 	return nil
 }
 
-func (r *RecordRepository) AddRecordByGitHubID(githubID int64, record database.Record) error {
+func (r *RecordRepository) AddRecordByGitHubID(ctx context.Context, githubID int64, record database.Record) error {
 	var userID uuid.UUID
-	err := r.db.QueryRow(`SELECT id FROM users WHERE github_id = $1`, githubID).Scan(&userID)
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM users WHERE github_id = $1`, githubID).Scan(&userID)
 	if err != nil {
-		return fmt.Errorf("user not found or query failed: %v", err)
+		return fmt.Errorf("user not found or query failed: %w", err)
 	}
 
-	_, err = r.db.Exec(
+	_, err = r.db.ExecContext(ctx,
 		`INSERT INTO records (user_id, record_name, record_type, record_value, ttl, is_active, cloudflare_record_id)
          VALUES ($1, $2, $3, $4, $5, $6, $7)`,
 		userID, record.RecordName, record.RecordType, record.RecordValue, record.TTL, record.IsActive, record.CloudflareRecordID,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to insert record: %v", err)
+		return fmt.Errorf("failed to insert record: %w", err)
 	}
 
 	return nil
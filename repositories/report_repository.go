@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"btwarch/database"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const reportColumns = `id, subdomain_name, category, evidence, reporter_email, reporter_ip, status, resolved_by, resolution_note, resolved_at, created_at, updated_at`
+
+type ReportRepository struct {
+	db *sql.DB
+}
+
+func NewReportRepository() *ReportRepository {
+	return &ReportRepository{db: database.DB}
+}
+
+func scanReport(row rowScanner) (*database.AbuseReport, error) {
+	report := &database.AbuseReport{}
+	err := row.Scan(
+		&report.ID, &report.SubdomainName, &report.Category, &report.Evidence,
+		&report.ReporterEmail, &report.ReporterIP, &report.Status,
+		&report.ResolvedBy, &report.ResolutionNote, &report.ResolvedAt,
+		&report.CreatedAt, &report.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (r *ReportRepository) CreateReport(ctx context.Context, subdomainName, category, evidence string, reporterEmail, reporterIP *string) (*database.AbuseReport, error) {
+	query := `
+		INSERT INTO abuse_reports (subdomain_name, category, evidence, reporter_email, reporter_ip)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + reportColumns
+
+	report, err := scanReport(r.db.QueryRowContext(ctx, query, subdomainName, category, evidence, reporterEmail, reporterIP))
+	if err != nil {
+		return nil, fmt.Errorf("error creating report: %w", err)
+	}
+
+	return report, nil
+}
+
+func (r *ReportRepository) GetReportByID(ctx context.Context, reportID uuid.UUID) (*database.AbuseReport, error) {
+	query := `SELECT ` + reportColumns + ` FROM abuse_reports WHERE id = $1`
+
+	report, err := scanReport(r.db.QueryRowContext(ctx, query, reportID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting report: %w", err)
+	}
+
+	return report, nil
+}
+
+// ListReports returns a page of reports, oldest first so the queue is worked
+// in order, optionally filtered by status and subdomain, with the total match
+// count.
+func (r *ReportRepository) ListReports(ctx context.Context, status, subdomainName string, limit, offset int) ([]*database.AbuseReport, int, error) {
+	where := `
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR subdomain_name = $2)
+	`
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM abuse_reports`+where, status, subdomainName).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting reports: %w", err)
+	}
+
+	query := `SELECT ` + reportColumns + ` FROM abuse_reports` + where + ` ORDER BY created_at ASC LIMIT $3 OFFSET $4`
+	rows, err := r.db.QueryContext(ctx, query, status, subdomainName, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []*database.AbuseReport{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, total, nil
+}
+
+// ResolveReport closes an open report with the given status. It returns false
+// if the report was already resolved.
+func (r *ReportRepository) ResolveReport(ctx context.Context, reportID uuid.UUID, status string, resolvedBy uuid.UUID, note string) (bool, error) {
+	query := `
+		UPDATE abuse_reports
+		SET status = $1, resolved_by = $2, resolution_note = NULLIF($3, ''), resolved_at = $4, updated_at = $4
+		WHERE id = $5 AND status = 'open'
+	`
+
+	res, err := r.db.ExecContext(ctx, query, status, resolvedBy, note, time.Now(), reportID)
+	if err != nil {
+		return false, fmt.Errorf("error resolving report: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ResolveOpenReportsBySubdomain closes every open report for the subdomain
+// with the given status and returns how many were closed.
+func (r *ReportRepository) ResolveOpenReportsBySubdomain(ctx context.Context, subdomainName, status string, resolvedBy uuid.UUID, note string) (int64, error) {
+	query := `
+		UPDATE abuse_reports
+		SET status = $1, resolved_by = $2, resolution_note = NULLIF($3, ''), resolved_at = $4, updated_at = $4
+		WHERE subdomain_name = $5 AND status = 'open'
+	`
+
+	res, err := r.db.ExecContext(ctx, query, status, resolvedBy, note, time.Now(), subdomainName)
+	if err != nil {
+		return 0, fmt.Errorf("error resolving reports: %w", err)
+	}
+
+	return res.RowsAffected()
+}
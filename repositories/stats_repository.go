@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"btwarch/database"
+	"btwarch/metrics"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type StatsRepository struct {
+	db *sql.DB
+}
+
+func NewStatsRepository() *StatsRepository {
+	return &StatsRepository{db: database.DB}
+}
+
+func (r *StatsRepository) GetBusinessStats(ctx context.Context) (*metrics.BusinessStats, error) {
+	stats := &metrics.BusinessStats{
+		ClaimsByStatus: map[string]int{
+			database.ClaimStatusActive:   0,
+			database.ClaimStatusWarned:   0,
+			database.ClaimStatusCooldown: 0,
+		},
+		ActiveRecordsByType: map[string]int{},
+	}
+
+	query := `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE suspended_at IS NOT NULL AND (suspended_until IS NULL OR suspended_until > NOW()))
+		FROM users
+	`
+	if err := r.db.QueryRowContext(ctx, query).Scan(&stats.Users, &stats.SuspendedUsers); err != nil {
+		return nil, fmt.Errorf("error counting users: %w", err)
+	}
+
+	if err := r.countBy(ctx, `SELECT status, COUNT(*) FROM subdomain_claims GROUP BY status`, stats.ClaimsByStatus); err != nil {
+		return nil, fmt.Errorf("error counting claims: %w", err)
+	}
+
+	if err := r.countBy(ctx, `SELECT record_type, COUNT(*) FROM records WHERE is_active = true GROUP BY record_type`, stats.ActiveRecordsByType); err != nil {
+		return nil, fmt.Errorf("error counting records: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (r *StatsRepository) countBy(ctx context.Context, query string, counts map[string]int) error {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return err
+		}
+		counts[key] = count
+	}
+
+	return rows.Err()
+}
@@ -2,12 +2,24 @@ package repositories
 
 import (
 	"btwarch/database"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const subdomainClaimColumns = `id, user_id, subdomain_name, display_name, skeleton, is_public, description, status, last_activity_at, status_changed_at,
+	verify_by, verified_at, last_verification_at, last_verification_error, moderated_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
 type SubdomainClaimRepository struct {
 	db *sql.DB
 }
@@ -16,111 +28,416 @@ func NewSubdomainClaimRepository() *SubdomainClaimRepository {
 	return &SubdomainClaimRepository{db: database.DB}
 }
 
-func (r *SubdomainClaimRepository) CreateClaim(userID uuid.UUID, subdomainName string) (*database.SubdomainClaim, error) {
+func scanSubdomainClaim(row rowScanner) (*database.SubdomainClaim, error) {
+	claim := &database.SubdomainClaim{}
+	err := row.Scan(
+		&claim.ID, &claim.UserId, &claim.SubdomainName, &claim.DisplayName, &claim.Skeleton,
+		&claim.IsPublic, &claim.Description, &claim.Status,
+		&claim.LastActivityAt, &claim.StatusChangedAt,
+		&claim.VerifyBy, &claim.VerifiedAt, &claim.LastVerificationAt, &claim.LastVerificationError,
+		&claim.ModeratedAt, &claim.CreatedAt, &claim.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+func (r *SubdomainClaimRepository) queryClaims(ctx context.Context, query string, args ...any) ([]*database.SubdomainClaim, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting subdomain claims: %w", err)
+	}
+	defer rows.Close()
+
+	var claims []*database.SubdomainClaim
+	for rows.Next() {
+		claim, err := scanSubdomainClaim(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning subdomain claim: %w", err)
+		}
+		claims = append(claims, claim)
+	}
+
+	return claims, nil
+}
+
+// ErrSubdomainTaken and ErrSubdomainTooSimilar are returned by CreateClaim
+// when the name, or a name with the same skeleton, is already claimed.
+var (
+	ErrSubdomainTaken      = errors.New("subdomain already claimed")
+	ErrSubdomainTooSimilar = errors.New("subdomain name is too similar to an existing claim")
+)
+
+// uniqueViolation is the SQLSTATE PostgreSQL reports when an insert conflicts
+// with a unique index.
+const uniqueViolation = "23505"
+
+// CreateClaim inserts a claim. Claims with the same skeleton are serialized
+// with an advisory lock, so concurrent requests for the same or confusable
+// names cannot both succeed; the unique index on the lowercased name backs
+// this up. A nil verifyBy means the claim does not need to prove liveness.
+func (r *SubdomainClaimRepository) CreateClaim(ctx context.Context, userID uuid.UUID, subdomainName, displayName, skeleton string, verifyBy *time.Time) (*database.SubdomainClaim, error) {
 	// Check if user already has a subdomain claim
-	existingClaim, err := r.GetClaimByUserID(userID)
+	existingClaim, err := r.GetClaimByUserID(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("error checking existing claims: %v", err)
+		return nil, fmt.Errorf("error checking existing claims: %w", err)
 	}
 	if existingClaim != nil {
 		return nil, fmt.Errorf("user already has a subdomain claim. Only one subdomain per user is allowed")
 	}
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('subdomain_claims:' || $1))`, skeleton); err != nil {
+		return nil, fmt.Errorf("error locking subdomain skeleton: %w", err)
+	}
+
+	var conflicting string
+	err = tx.QueryRowContext(ctx,
+		`SELECT subdomain_name FROM subdomain_claims WHERE LOWER(subdomain_name) = LOWER($1) OR skeleton = $2 LIMIT 1`,
+		subdomainName, skeleton,
+	).Scan(&conflicting)
+	switch {
+	case err == nil && strings.EqualFold(conflicting, subdomainName):
+		return nil, ErrSubdomainTaken
+	case err == nil:
+		return nil, ErrSubdomainTooSimilar
+	case err != sql.ErrNoRows:
+		return nil, fmt.Errorf("error checking conflicting claims: %w", err)
+	}
+
 	query := `
-		INSERT INTO subdomain_claims (user_id, subdomain_name)
-		VALUES ($1, $2)
-		RETURNING id, user_id, subdomain_name, created_at, updated_at
-	`
+		INSERT INTO subdomain_claims (user_id, subdomain_name, display_name, skeleton, verify_by, verified_at)
+		VALUES ($1, $2, $3, $4, $5, CASE WHEN $5::timestamp IS NULL THEN NOW() END)
+		RETURNING ` + subdomainClaimColumns
 
-	claim := &database.SubdomainClaim{}
-	err = r.db.QueryRow(
-		query,
-		userID, subdomainName,
-	).Scan(
-		&claim.ID, &claim.UserId, &claim.SubdomainName, &claim.CreatedAt, &claim.UpdatedAt,
-	)
+	claim, err := scanSubdomainClaim(tx.QueryRowContext(ctx, query, userID, subdomainName, displayName, skeleton, verifyBy))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return nil, ErrSubdomainTaken
+		}
+		return nil, fmt.Errorf("error creating subdomain claim: %w", err)
+	}
+
+	return claim, nil
+}
+
+func (r *SubdomainClaimRepository) GetClaimBySubdomain(ctx context.Context, subdomainName string) (*database.SubdomainClaim, error) {
+	query := `SELECT ` + subdomainClaimColumns + ` FROM subdomain_claims WHERE subdomain_name = $1`
 
+	claim, err := scanSubdomainClaim(r.db.QueryRowContext(ctx, query, subdomainName))
 	if err != nil {
-		return nil, fmt.Errorf("error creating subdomain claim: %v", err)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting subdomain claim: %w", err)
 	}
 
 	return claim, nil
 }
 
-func (r *SubdomainClaimRepository) GetClaimBySubdomain(subdomainName string) (*database.SubdomainClaim, error) {
-	query := `
-		SELECT id, user_id, subdomain_name, created_at, updated_at
-		FROM subdomain_claims WHERE subdomain_name = $1
-	`
+// GetConfusableClaim returns a claim whose skeleton matches but whose name
+// differs from subdomainName, if any.
+func (r *SubdomainClaimRepository) GetConfusableClaim(ctx context.Context, subdomainName, skeleton string) (*database.SubdomainClaim, error) {
+	query := `SELECT ` + subdomainClaimColumns + ` FROM subdomain_claims WHERE skeleton = $1 AND subdomain_name <> $2 LIMIT 1`
 
-	claim := &database.SubdomainClaim{}
-	err := r.db.QueryRow(query, subdomainName).Scan(
-		&claim.ID, &claim.UserId, &claim.SubdomainName, &claim.CreatedAt, &claim.UpdatedAt,
-	)
+	claim, err := scanSubdomainClaim(r.db.QueryRowContext(ctx, query, skeleton, subdomainName))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting subdomain claim: %w", err)
+	}
 
+	return claim, nil
+}
+
+func (r *SubdomainClaimRepository) GetClaimByID(ctx context.Context, claimID uuid.UUID) (*database.SubdomainClaim, error) {
+	query := `SELECT ` + subdomainClaimColumns + ` FROM subdomain_claims WHERE id = $1`
+
+	claim, err := scanSubdomainClaim(r.db.QueryRowContext(ctx, query, claimID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("error getting subdomain claim: %v", err)
+		return nil, fmt.Errorf("error getting subdomain claim: %w", err)
 	}
 
 	return claim, nil
 }
 
-func (r *SubdomainClaimRepository) GetClaimByUserID(userID uuid.UUID) (*database.SubdomainClaim, error) {
-	query := `
-		SELECT id, user_id, subdomain_name, created_at, updated_at
-		FROM subdomain_claims WHERE user_id = $1
+// SearchClaims returns a page of claims whose name matches the search term, or
+// whose ID or owner ID equals it, with the total match count.
+func (r *SubdomainClaimRepository) SearchClaims(ctx context.Context, search string, limit, offset int) ([]*database.SubdomainClaim, int, error) {
+	where := `
+		WHERE $1 = '' OR subdomain_name ILIKE $2 OR display_name ILIKE $2 OR id::text = $1 OR user_id::text = $1
 	`
+	pattern := "%" + escapeLike(search) + "%"
 
-	claim := &database.SubdomainClaim{}
-	err := r.db.QueryRow(query, userID).Scan(
-		&claim.ID, &claim.UserId, &claim.SubdomainName, &claim.CreatedAt, &claim.UpdatedAt,
-	)
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM subdomain_claims`+where, search, pattern).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting subdomain claims: %w", err)
+	}
 
+	query := `SELECT ` + subdomainClaimColumns + ` FROM subdomain_claims` + where + ` ORDER BY created_at DESC LIMIT $3 OFFSET $4`
+	claims, err := r.queryClaims(ctx, query, search, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if claims == nil {
+		claims = []*database.SubdomainClaim{}
+	}
+
+	return claims, total, nil
+}
+
+func (r *SubdomainClaimRepository) GetClaimByUserID(ctx context.Context, userID uuid.UUID) (*database.SubdomainClaim, error) {
+	query := `SELECT ` + subdomainClaimColumns + ` FROM subdomain_claims WHERE user_id = $1`
+
+	claim, err := scanSubdomainClaim(r.db.QueryRowContext(ctx, query, userID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("error getting subdomain claim: %v", err)
+		return nil, fmt.Errorf("error getting subdomain claim: %w", err)
 	}
 
 	return claim, nil
 }
 
-func (r *SubdomainClaimRepository) GetClaimsByUserID(userID uuid.UUID) ([]*database.SubdomainClaim, error) {
+func (r *SubdomainClaimRepository) GetClaimsByUserID(ctx context.Context, userID uuid.UUID) ([]*database.SubdomainClaim, error) {
+	query := `SELECT ` + subdomainClaimColumns + ` FROM subdomain_claims WHERE user_id = $1 ORDER BY created_at DESC`
+	return r.queryClaims(ctx, query, userID)
+}
+
+// GetInactiveClaims returns active claims that have no records and have seen no
+// owner activity (logins or record changes) for the given number of days.
+func (r *SubdomainClaimRepository) GetInactiveClaims(ctx context.Context, inactiveDays int, parentDomain string) ([]*database.SubdomainClaim, error) {
+	query := `
+		SELECT ` + subdomainClaimColumns + `
+		FROM subdomain_claims
+		WHERE status = $1
+		AND last_activity_at < NOW() - ($2::int * INTERVAL '1 day')
+		AND NOT EXISTS (
+			SELECT 1 FROM records
+			WHERE records.record_name = subdomain_claims.subdomain_name || '.' || $3::text
+			OR records.record_name LIKE '%.' || subdomain_claims.subdomain_name || '.' || $3::text
+		)
+	`
+	return r.queryClaims(ctx, query, database.ClaimStatusActive, inactiveDays, parentDomain)
+}
+
+// GetClaimsInStatusFor returns claims that have been in the given status for
+// longer than the given number of days.
+func (r *SubdomainClaimRepository) GetClaimsInStatusFor(ctx context.Context, status string, days int) ([]*database.SubdomainClaim, error) {
+	query := `
+		SELECT ` + subdomainClaimColumns + `
+		FROM subdomain_claims
+		WHERE status = $1
+		AND status_changed_at < NOW() - ($2::int * INTERVAL '1 day')
+	`
+	return r.queryClaims(ctx, query, status, days)
+}
+
+func (r *SubdomainClaimRepository) UpdateClaimStatus(ctx context.Context, claimID uuid.UUID, status string) error {
+	query := `
+		UPDATE subdomain_claims
+		SET status = $1, status_changed_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, status, claimID)
+	if err != nil {
+		return fmt.Errorf("error updating subdomain claim status: %w", err)
+	}
+	return nil
+}
+
+// ReactivateClaim brings a claim back to the active state and resets its
+// activity clock.
+func (r *SubdomainClaimRepository) ReactivateClaim(ctx context.Context, claimID uuid.UUID) error {
+	query := `
+		UPDATE subdomain_claims
+		SET status = $1, status_changed_at = NOW(), last_activity_at = NOW(), updated_at = NOW()
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, database.ClaimStatusActive, claimID)
+	if err != nil {
+		return fmt.Errorf("error reactivating subdomain claim: %w", err)
+	}
+	return nil
+}
+
+// TouchActivityByUserID records owner activity on the user's claim. A claim
+// that has been warned about inactivity goes back to active; claims in
+// cooldown must be re-claimed explicitly.
+func (r *SubdomainClaimRepository) TouchActivityByUserID(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE subdomain_claims
+		SET last_activity_at = NOW(),
+			status = CASE WHEN status = $1 THEN $2 ELSE status END,
+			status_changed_at = CASE WHEN status = $1 THEN NOW() ELSE status_changed_at END
+		WHERE user_id = $3
+	`
+	_, err := r.db.ExecContext(ctx, query, database.ClaimStatusWarned, database.ClaimStatusActive, userID)
+	if err != nil {
+		return fmt.Errorf("error updating subdomain claim activity: %w", err)
+	}
+	return nil
+}
+
+func (r *SubdomainClaimRepository) GetUnverifiedClaims(ctx context.Context) ([]*database.SubdomainClaim, error) {
+	query := `SELECT ` + subdomainClaimColumns + ` FROM subdomain_claims WHERE verified_at IS NULL ORDER BY created_at`
+	return r.queryClaims(ctx, query)
+}
+
+// GetUnverifiedClaimsPastDeadline returns claims that did not prove liveness
+// before their verification deadline.
+func (r *SubdomainClaimRepository) GetUnverifiedClaimsPastDeadline(ctx context.Context) ([]*database.SubdomainClaim, error) {
+	query := `
+		SELECT ` + subdomainClaimColumns + `
+		FROM subdomain_claims
+		WHERE verified_at IS NULL AND verify_by < NOW()
+	`
+	return r.queryClaims(ctx, query)
+}
+
+func (r *SubdomainClaimRepository) MarkClaimVerified(ctx context.Context, claimID uuid.UUID) error {
+	query := `
+		UPDATE subdomain_claims
+		SET verified_at = NOW(), last_verification_at = NOW(), last_verification_error = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, claimID)
+	if err != nil {
+		return fmt.Errorf("error marking subdomain claim verified: %w", err)
+	}
+	return nil
+}
+
+func (r *SubdomainClaimRepository) RecordVerificationFailure(ctx context.Context, claimID uuid.UUID, reason string) error {
+	query := `
+		UPDATE subdomain_claims
+		SET last_verification_at = NOW(), last_verification_error = $1, updated_at = NOW()
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, reason, claimID)
+	if err != nil {
+		return fmt.Errorf("error recording subdomain claim verification: %w", err)
+	}
+	return nil
+}
+
+func (r *SubdomainClaimRepository) UpdateClaimVisibility(ctx context.Context, claimID uuid.UUID, isPublic bool, description string) error {
+	query := `
+		UPDATE subdomain_claims
+		SET is_public = $1, description = $2, updated_at = NOW()
+		WHERE id = $3
+	`
+	_, err := r.db.ExecContext(ctx, query, isPublic, description, claimID)
+	if err != nil {
+		return fmt.Errorf("error updating subdomain claim visibility: %w", err)
+	}
+	return nil
+}
+
+// SetModerated locks the claim so that none of its records can be enabled,
+// or lifts that lock.
+func (r *SubdomainClaimRepository) SetModerated(ctx context.Context, claimID uuid.UUID, moderated bool) error {
+	query := `
+		UPDATE subdomain_claims
+		SET moderated_at = CASE WHEN $1 THEN $2 ELSE NULL END, updated_at = $2
+		WHERE id = $3
+	`
+	if _, err := r.db.ExecContext(ctx, query, moderated, time.Now(), claimID); err != nil {
+		return fmt.Errorf("error updating claim moderation: %w", err)
+	}
+	return nil
+}
+
+var directorySortOrders = map[string]string{
+	"newest": "c.created_at DESC",
+	"oldest": "c.created_at ASC",
+	"name":   "c.subdomain_name ASC",
+}
+
+// ListPublicClaims returns a page of active, public claims joined with their
+// owner, along with the total number of matching claims. Unknown sort values
+// fall back to newest first.
+func (r *SubdomainClaimRepository) ListPublicClaims(ctx context.Context, search, sort string, limit, offset int) ([]*database.DirectoryEntry, int, error) {
+	orderBy, ok := directorySortOrders[sort]
+	if !ok {
+		orderBy = directorySortOrders["newest"]
+	}
+
+	// The total is counted separately: a window count over the page would
+	// come back as zero for a page past the last one. Claims of users who are
+	// currently suspended are left out.
+	from := `
+		FROM subdomain_claims c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.is_public AND c.status = $1
+		AND (u.suspended_at IS NULL OR (u.suspended_until IS NOT NULL AND u.suspended_until <= NOW()))
+		AND ($2 = '' OR c.subdomain_name ILIKE $3 OR c.display_name ILIKE $3 OR c.description ILIKE $3 OR u.username ILIKE $3)
+	`
+	pattern := "%" + escapeLike(search) + "%"
+
+	var total int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) `+from, database.ClaimStatusActive, search, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting public claims: %w", err)
+	}
+
 	query := `
-		SELECT id, user_id, subdomain_name, created_at, updated_at
-		FROM subdomain_claims WHERE user_id = $1 ORDER BY created_at DESC
+		SELECT c.subdomain_name, c.display_name, c.description, c.created_at,
+			u.username, COALESCE(u.avatar_url, '')
+	` + from + `
+		ORDER BY ` + orderBy + `
+		LIMIT $4 OFFSET $5
 	`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, database.ClaimStatusActive, search, pattern, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("error getting subdomain claims: %v", err)
+		return nil, 0, fmt.Errorf("error listing public claims: %w", err)
 	}
 	defer rows.Close()
 
-	var claims []*database.SubdomainClaim
+	entries := []*database.DirectoryEntry{}
 	for rows.Next() {
-		claim := &database.SubdomainClaim{}
+		entry := &database.DirectoryEntry{}
 		err := rows.Scan(
-			&claim.ID, &claim.UserId, &claim.SubdomainName, &claim.CreatedAt, &claim.UpdatedAt,
+			&entry.SubdomainName, &entry.DisplayName, &entry.Description, &entry.CreatedAt,
+			&entry.Username, &entry.AvatarURL,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning subdomain claim: %v", err)
+			return nil, 0, fmt.Errorf("error scanning directory entry: %w", err)
 		}
-		claims = append(claims, claim)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error listing public claims: %w", err)
 	}
 
-	return claims, nil
+	return entries, total, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
 }
 
-func (r *SubdomainClaimRepository) DeleteClaim(claimID uuid.UUID) error {
+func (r *SubdomainClaimRepository) DeleteClaim(ctx context.Context, claimID uuid.UUID) error {
 	query := `DELETE FROM subdomain_claims WHERE id = $1`
-	_, err := r.db.Exec(query, claimID)
+	_, err := r.db.ExecContext(ctx, query, claimID)
 	if err != nil {
-		return fmt.Errorf("error deleting subdomain claim: %v", err)
+		return fmt.Errorf("error deleting subdomain claim: %w", err)
 	}
 	return nil
 }
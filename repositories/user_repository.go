@@ -2,11 +2,18 @@ package repositories
 
 import (
 	"btwarch/database"
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+const userColumns = `id, github_id, username, email, avatar_url, access_token, role,
+	(suspended_at IS NOT NULL AND (suspended_until IS NULL OR suspended_until > NOW())),
+	suspended_at, suspended_until, suspension_reason, created_at, updated_at`
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -15,81 +22,195 @@ func NewUserRepository() *UserRepository {
 	return &UserRepository{db: database.DB}
 }
 
-func (r *UserRepository) CreateUser(githubID int64, username, email, avatarURL, accessToken string) (*database.User, error) {
-	query := `
-		INSERT INTO users (github_id, username, email, avatar_url, access_token)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, github_id, username, email, avatar_url, access_token, created_at, updated_at
-	`
-
+func scanUser(row rowScanner) (*database.User, error) {
 	user := &database.User{}
-	err := r.db.QueryRow(
-		query,
-		githubID, username, email, avatarURL, accessToken,
-	).Scan(
+	err := row.Scan(
 		&user.ID, &user.GitHubID, &user.Username, &user.Email,
-		&user.AvatarURL, &user.AccessToken,
+		&user.AvatarURL, &user.AccessToken, &user.Role,
+		&user.Suspended, &user.SuspendedAt, &user.SuspendedUntil, &user.SuspensionReason,
 		&user.CreatedAt, &user.UpdatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
 
+func (r *UserRepository) CreateUser(ctx context.Context, githubID int64, username, email, avatarURL, accessToken string) (*database.User, error) {
+	query := `
+		INSERT INTO users (github_id, username, email, avatar_url, access_token)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + userColumns
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, githubID, username, email, avatarURL, accessToken))
 	if err != nil {
-		return nil, fmt.Errorf("error creating user: %v", err)
+		return nil, fmt.Errorf("error creating user: %w", err)
 	}
 
 	return user, nil
 }
 
-func (r *UserRepository) GetUserByGitHubID(githubID int64) (*database.User, error) {
-	query := `
-		SELECT id, github_id, username, email, avatar_url, access_token, created_at, updated_at
-		FROM users WHERE github_id = $1
-	`
+func (r *UserRepository) GetUserByGitHubID(ctx context.Context, githubID int64) (*database.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE github_id = $1`
 
-	user := &database.User{}
-	err := r.db.QueryRow(query, githubID).Scan(
-		&user.ID, &user.GitHubID, &user.Username, &user.Email,
-		&user.AvatarURL, &user.AccessToken,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, githubID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
 
+	return user, nil
+}
+
+func (r *UserRepository) GetUserByID(ctx context.Context, userID uuid.UUID) (*database.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, userID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("error getting user: %v", err)
+		return nil, fmt.Errorf("error getting user: %w", err)
 	}
 
 	return user, nil
 }
 
-func (r *UserRepository) UpdateUserTokens(userID string, accessToken string) error {
+// SearchUsers returns a page of users whose username or email matches the
+// search term, or whose ID or GitHub ID equals it, with the total match count.
+func (r *UserRepository) SearchUsers(ctx context.Context, search string, limit, offset int) ([]*database.User, int, error) {
+	where := `
+		WHERE $1 = '' OR username ILIKE $2 OR email ILIKE $2 OR id::text = $1 OR github_id::text = $1
+	`
+	pattern := "%" + escapeLike(search) + "%"
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+where, search, pattern).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting users: %w", err)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users` + where + ` ORDER BY created_at DESC LIMIT $3 OFFSET $4`
+	rows, err := r.db.QueryContext(ctx, query, search, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error searching users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*database.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, total, nil
+}
+
+func (r *UserRepository) UpdateUserTokens(ctx context.Context, userID string, accessToken string) error {
 	query := `
-		UPDATE users 
+		UPDATE users
 		SET access_token = $1, updated_at = $2
 		WHERE id = $3
 	`
 
-	_, err := r.db.Exec(query, accessToken, time.Now(), userID)
+	_, err := r.db.ExecContext(ctx, query, accessToken, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("error updating user tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (r *UserRepository) UpdateUserRole(ctx context.Context, userID uuid.UUID, role string) error {
+	query := `
+		UPDATE users
+		SET role = $1, updated_at = $2
+		WHERE id = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, role, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("error updating user role: %w", err)
+	}
+
+	return nil
+}
+
+// SuspendUser marks the user as suspended. A nil until suspends the user
+// indefinitely.
+func (r *UserRepository) SuspendUser(ctx context.Context, userID uuid.UUID, reason string, until *time.Time) error {
+	query := `
+		UPDATE users
+		SET suspended_at = $1, suspended_until = $2, suspension_reason = $3, updated_at = $1
+		WHERE id = $4
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now(), until, reason, userID)
 	if err != nil {
-		return fmt.Errorf("error updating user tokens: %v", err)
+		return fmt.Errorf("error suspending user: %w", err)
 	}
 
 	return nil
 }
 
-func (r *UserRepository) InsertUser(user database.User) (int64, error) {
-	res, err := r.db.Exec(
+func (r *UserRepository) UnsuspendUser(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET suspended_at = NULL, suspended_until = NULL, suspension_reason = NULL, updated_at = $1
+		WHERE id = $2
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("error unsuspending user: %w", err)
+	}
+
+	return nil
+}
+
+// GetExpiredSuspensions returns users whose suspension has run out but has not
+// been lifted yet.
+func (r *UserRepository) GetExpiredSuspensions(ctx context.Context) ([]*database.User, error) {
+	query := `
+		SELECT ` + userColumns + ` FROM users
+		WHERE suspended_at IS NOT NULL AND suspended_until IS NOT NULL AND suspended_until <= NOW()
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting expired suspensions: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*database.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (r *UserRepository) InsertUser(ctx context.Context, user database.User) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
 		`INSERT INTO users (github_id, username, email, avatar_url, access_token)
          VALUES ($1, $2, $3, $4, $5)`,
 		user.GitHubID, user.Username, user.Email, user.AvatarURL, user.AccessToken,
 	)
 	if err != nil {
-		return 0, fmt.Errorf("failed to insert user: %v", err)
+		return 0, fmt.Errorf("failed to insert user: %w", err)
 	}
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
 	if rowsAffected == 0 {
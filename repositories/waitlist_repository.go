@@ -0,0 +1,246 @@
+package repositories
+
+import (
+	"btwarch/database"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WaitlistRepository struct {
+	db *sql.DB
+}
+
+func NewWaitlistRepository() *WaitlistRepository {
+	return &WaitlistRepository{db: database.DB}
+}
+
+func (r *WaitlistRepository) JoinWaitlist(ctx context.Context, userID uuid.UUID, subdomainName string) (*database.WaitlistEntry, error) {
+	query := `
+		INSERT INTO subdomain_waitlist (user_id, subdomain_name)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, subdomain_name) DO NOTHING
+	`
+	if _, err := r.db.ExecContext(ctx, query, userID, subdomainName); err != nil {
+		return nil, fmt.Errorf("error joining waitlist: %w", err)
+	}
+
+	entries, err := r.queryEntries(ctx, `WHERE w.user_id = $1 AND w.subdomain_name = $2`, userID, subdomainName)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("error joining waitlist: entry not found")
+	}
+
+	return entries[0], nil
+}
+
+func (r *WaitlistRepository) LeaveWaitlist(ctx context.Context, userID uuid.UUID, subdomainName string) (bool, error) {
+	query := `DELETE FROM subdomain_waitlist WHERE user_id = $1 AND subdomain_name = $2`
+	res, err := r.db.ExecContext(ctx, query, userID, subdomainName)
+	if err != nil {
+		return false, fmt.Errorf("error leaving waitlist: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *WaitlistRepository) GetWaitlistByUserID(ctx context.Context, userID uuid.UUID) ([]*database.WaitlistEntry, error) {
+	return r.queryEntries(ctx, `WHERE w.user_id = $1 ORDER BY w.created_at`, userID)
+}
+
+func (r *WaitlistRepository) CountWaitlist(ctx context.Context, subdomainName string) (int, error) {
+	query := `SELECT COUNT(*) FROM subdomain_waitlist WHERE subdomain_name = $1`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, subdomainName).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting waitlist: %w", err)
+	}
+	return count, nil
+}
+
+func (r *WaitlistRepository) queryEntries(ctx context.Context, where string, args ...any) ([]*database.WaitlistEntry, error) {
+	query := `
+		SELECT w.id, w.user_id, w.subdomain_name, w.created_at,
+			(SELECT COUNT(*) FROM subdomain_waitlist o
+			 WHERE o.subdomain_name = w.subdomain_name AND o.created_at <= w.created_at) AS position
+		FROM subdomain_waitlist w
+	` + where
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting waitlist: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*database.WaitlistEntry
+	for rows.Next() {
+		entry := &database.WaitlistEntry{}
+		err := rows.Scan(&entry.ID, &entry.UserId, &entry.SubdomainName, &entry.CreatedAt, &entry.Position)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning waitlist entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (r *WaitlistRepository) GetActiveReservation(ctx context.Context, subdomainName string) (*database.SubdomainReservation, error) {
+	query := `
+		SELECT id, user_id, subdomain_name, expires_at, created_at
+		FROM subdomain_reservations WHERE subdomain_name = $1 AND expires_at > NOW()
+	`
+
+	reservation := &database.SubdomainReservation{}
+	err := r.db.QueryRowContext(ctx, query, subdomainName).Scan(
+		&reservation.ID, &reservation.UserId, &reservation.SubdomainName, &reservation.ExpiresAt, &reservation.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting reservation: %w", err)
+	}
+
+	return reservation, nil
+}
+
+func (r *WaitlistRepository) GetReservationsByUserID(ctx context.Context, userID uuid.UUID) ([]*database.SubdomainReservation, error) {
+	query := `
+		SELECT id, user_id, subdomain_name, expires_at, created_at
+		FROM subdomain_reservations WHERE user_id = $1 AND expires_at > NOW() ORDER BY expires_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var reservations []*database.SubdomainReservation
+	for rows.Next() {
+		reservation := &database.SubdomainReservation{}
+		err := rows.Scan(&reservation.ID, &reservation.UserId, &reservation.SubdomainName, &reservation.ExpiresAt, &reservation.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning reservation: %w", err)
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, nil
+}
+
+// GetExpiredReservationNames returns the names whose reservation ran out
+// without being claimed.
+func (r *WaitlistRepository) GetExpiredReservationNames(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT subdomain_name FROM subdomain_reservations WHERE expires_at <= NOW()`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting expired reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("error scanning reservation: %w", err)
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+func (r *WaitlistRepository) DeleteReservation(ctx context.Context, subdomainName string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM subdomain_reservations WHERE subdomain_name = $1`, subdomainName)
+	if err != nil {
+		return fmt.Errorf("error deleting reservation: %w", err)
+	}
+	return nil
+}
+
+// PromoteNext gives the first user on the waitlist an exclusive reservation
+// for the name and removes them from the waitlist. It returns the new
+// reservation and the users still waiting. The reservation is nil when the
+// waitlist is empty or the name is already reserved.
+func (r *WaitlistRepository) PromoteNext(ctx context.Context, subdomainName string, ttl time.Duration) (*database.SubdomainReservation, []uuid.UUID, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM subdomain_reservations WHERE subdomain_name = $1 AND expires_at <= NOW()`, subdomainName); err != nil {
+		return nil, nil, fmt.Errorf("error clearing expired reservation: %w", err)
+	}
+
+	var reserved bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM subdomain_reservations WHERE subdomain_name = $1)`, subdomainName).Scan(&reserved)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error checking reservation: %w", err)
+	}
+	if reserved {
+		return nil, nil, tx.Commit()
+	}
+
+	var entryID, userID uuid.UUID
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id FROM subdomain_waitlist
+		WHERE subdomain_name = $1
+		ORDER BY created_at
+		LIMIT 1
+		FOR UPDATE
+	`, subdomainName).Scan(&entryID, &userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, tx.Commit()
+		}
+		return nil, nil, fmt.Errorf("error getting next waitlist entry: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM subdomain_waitlist WHERE id = $1`, entryID); err != nil {
+		return nil, nil, fmt.Errorf("error removing waitlist entry: %w", err)
+	}
+
+	reservation := &database.SubdomainReservation{}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO subdomain_reservations (user_id, subdomain_name, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, user_id, subdomain_name, expires_at, created_at
+	`, userID, subdomainName, time.Now().Add(ttl)).Scan(
+		&reservation.ID, &reservation.UserId, &reservation.SubdomainName, &reservation.ExpiresAt, &reservation.CreatedAt,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating reservation: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT user_id FROM subdomain_waitlist WHERE subdomain_name = $1 ORDER BY created_at`, subdomainName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting waitlist: %w", err)
+	}
+	var waiting []uuid.UUID
+	for rows.Next() {
+		var waitingUserID uuid.UUID
+		if err := rows.Scan(&waitingUserID); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("error scanning waitlist entry: %w", err)
+		}
+		waiting = append(waiting, waitingUserID)
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit reservation: %w", err)
+	}
+
+	return reservation, waiting, nil
+}
@@ -0,0 +1,274 @@
+package repositories
+
+import (
+	"btwarch/database"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const webhookColumns = `id, user_id, url, secret, events, is_active, created_at, updated_at`
+
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+	d.last_response_status, d.last_error, d.delivered_at, d.created_at, d.updated_at`
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{db: database.DB}
+}
+
+// PendingDelivery is a delivery taken off the queue together with the
+// endpoint it goes to.
+type PendingDelivery struct {
+	Delivery      *database.WebhookDelivery
+	URL           string
+	Secret        string
+	WebhookActive bool
+}
+
+func scanWebhook(row rowScanner) (*database.Webhook, error) {
+	webhook := &database.Webhook{}
+	err := row.Scan(
+		&webhook.ID, &webhook.UserId, &webhook.URL, &webhook.Secret,
+		pq.Array(&webhook.Events), &webhook.IsActive, &webhook.CreatedAt, &webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func scanDelivery(row rowScanner, extra ...any) (*database.WebhookDelivery, error) {
+	delivery := &database.WebhookDelivery{}
+	dest := []any{
+		&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.Event, &delivery.Payload,
+		&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt,
+		&delivery.LastResponseStatus, &delivery.LastError, &delivery.DeliveredAt,
+		&delivery.CreatedAt, &delivery.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (r *WebhookRepository) CreateWebhook(ctx context.Context, userID uuid.UUID, url, secret string, events []string) (*database.Webhook, error) {
+	query := `
+		INSERT INTO webhooks (user_id, url, secret, events)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + webhookColumns
+
+	webhook, err := scanWebhook(r.db.QueryRowContext(ctx, query, userID, url, secret, pq.Array(events)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func (r *WebhookRepository) GetWebhookByID(ctx context.Context, webhookID uuid.UUID) (*database.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+
+	webhook, err := scanWebhook(r.db.QueryRowContext(ctx, query, webhookID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func (r *WebhookRepository) GetWebhooksByUserID(ctx context.Context, userID uuid.UUID) ([]*database.Webhook, error) {
+	return r.queryWebhooks(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE user_id = $1 ORDER BY created_at`, userID)
+}
+
+// GetActiveWebhooksForEvent returns the user's enabled webhooks subscribed to
+// the event type.
+func (r *WebhookRepository) GetActiveWebhooksForEvent(ctx context.Context, userID uuid.UUID, event string) ([]*database.Webhook, error) {
+	return r.queryWebhooks(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE user_id = $1 AND is_active AND $2 = ANY(events)`, userID, event)
+}
+
+func (r *WebhookRepository) queryWebhooks(ctx context.Context, query string, args ...any) ([]*database.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*database.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) CountWebhooksByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM webhooks WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting webhooks: %w", err)
+	}
+	return count, nil
+}
+
+func (r *WebhookRepository) UpdateWebhook(ctx context.Context, webhookID uuid.UUID, url string, events []string, isActive bool) error {
+	query := `
+		UPDATE webhooks
+		SET url = $1, events = $2, is_active = $3, updated_at = $4
+		WHERE id = $5
+	`
+
+	_, err := r.db.ExecContext(ctx, query, url, pq.Array(events), isActive, time.Now(), webhookID)
+	if err != nil {
+		return fmt.Errorf("error updating webhook: %w", err)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, webhookID)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, webhookID, eventID uuid.UUID, event string, payload []byte) (*database.WebhookDelivery, error) {
+	query := `
+		INSERT INTO webhook_deliveries AS d (webhook_id, event_id, event, payload)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + deliveryColumns
+
+	delivery, err := scanDelivery(r.db.QueryRowContext(ctx, query, webhookID, eventID, event, string(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+func (r *WebhookRepository) GetDeliveryByID(ctx context.Context, deliveryID uuid.UUID) (*database.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d WHERE d.id = $1`
+
+	delivery, err := scanDelivery(r.db.QueryRowContext(ctx, query, deliveryID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// ListDeliveries returns a page of the webhook's deliveries, newest first,
+// with the total count.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit, offset int) ([]*database.WebhookDelivery, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1`, webhookID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting webhook deliveries: %w", err)
+	}
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d WHERE d.webhook_id = $1 ORDER BY d.created_at DESC LIMIT $2 OFFSET $3`
+	rows, err := r.db.QueryContext(ctx, query, webhookID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*database.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, total, nil
+}
+
+// ClaimDueDeliveries takes up to limit pending deliveries whose next attempt
+// is due and pushes their next attempt back by lease, so that other instances
+// do not pick them up while they are being sent.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*PendingDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2::float8 * INTERVAL '1 second', updated_at = NOW()
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns + `, w.url, w.secret, w.is_active
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []*PendingDelivery
+	for rows.Next() {
+		p := &PendingDelivery{}
+		delivery, err := scanDelivery(rows, &p.URL, &p.Secret, &p.WebhookActive)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery: %w", err)
+		}
+		p.Delivery = delivery
+		pending = append(pending, p)
+	}
+
+	return pending, nil
+}
+
+func (r *WebhookRepository) MarkDeliverySucceeded(ctx context.Context, deliveryID uuid.UUID, responseStatus int) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'succeeded', attempts = attempts + 1, last_response_status = $1, last_error = NULL,
+			delivered_at = $2, updated_at = $2
+		WHERE id = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, responseStatus, time.Now(), deliveryID)
+	if err != nil {
+		return fmt.Errorf("error updating webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// MarkDeliveryFailed records a failed attempt. With a nil nextAttemptAt the
+// delivery is given up on; otherwise it is retried at that time.
+func (r *WebhookRepository) MarkDeliveryFailed(ctx context.Context, deliveryID uuid.UUID, responseStatus *int, errMessage string, nextAttemptAt *time.Time) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = CASE WHEN $3::timestamp IS NULL THEN 'failed' ELSE 'pending' END,
+			attempts = attempts + 1, last_response_status = $1, last_error = $2,
+			next_attempt_at = COALESCE($3::timestamp, next_attempt_at), updated_at = $4
+		WHERE id = $5
+	`
+
+	_, err := r.db.ExecContext(ctx, query, responseStatus, errMessage, nextAttemptAt, time.Now(), deliveryID)
+	if err != nil {
+		return fmt.Errorf("error updating webhook delivery: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/handlers"
+	"btwarch/lifecycle"
+	"btwarch/middleware"
+	"btwarch/notifications"
+	"btwarch/repositories"
+	"btwarch/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitAdminRouter(router fiber.Router, config *config.Config) {
+	userRepo := repositories.NewUserRepository()
+	recordRepo := repositories.NewRecordRepository(config)
+	subdomainClaimRepo := repositories.NewSubdomainClaimRepository()
+	notifier := notifications.NewNotifier(config)
+	adminHandler := handlers.NewAdminHandler(
+		config,
+		userRepo,
+		recordRepo,
+		subdomainClaimRepo,
+		repositories.NewReportRepository(),
+		lifecycle.NewClaimReleaser(config, subdomainClaimRepo, recordRepo, repositories.NewWaitlistRepository(), notifier),
+		lifecycle.NewUserSuspender(userRepo, recordRepo, notifier),
+		notifier,
+	)
+	authService := services.NewAuthService(
+		services.NewJWTKeys(config),
+		config.CookieDomain,
+		config.CookieSecure,
+		config.CookieSameSite,
+	)
+
+	adminGroup := router.Group("/admin")
+
+	adminGroup.Use(middleware.AuthMiddleware(authService, userRepo))
+	adminGroup.Use(middleware.RequireRole(userRepo, database.RoleAdmin))
+
+	adminGroup.Get("/users", adminHandler.ListUsers)
+	adminGroup.Get("/users/:id", adminHandler.GetUser)
+	adminGroup.Put("/users/:id/role", adminHandler.UpdateUserRole)
+	adminGroup.Post("/users/:id/suspend", adminHandler.SuspendUser)
+	adminGroup.Post("/users/:id/unsuspend", adminHandler.UnsuspendUser)
+
+	adminGroup.Get("/claims", adminHandler.ListClaims)
+	adminGroup.Post("/claims/:id/release", adminHandler.ReleaseClaim)
+	adminGroup.Post("/claims/:id/unlock", adminHandler.UnlockClaim)
+
+	adminGroup.Get("/records", adminHandler.ListRecords)
+	adminGroup.Post("/records/:id/disable", adminHandler.DisableRecord)
+	adminGroup.Post("/records/:id/unlock", adminHandler.UnlockRecord)
+
+	adminGroup.Get("/reports", adminHandler.ListReports)
+	adminGroup.Get("/reports/:id", adminHandler.GetReport)
+	adminGroup.Post("/reports/:id/action", adminHandler.ActionReport)
+	adminGroup.Post("/reports/:id/dismiss", adminHandler.DismissReport)
+}
@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/middleware"
+	"btwarch/stream"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiVersion prefixes every API route.
+const apiVersion = "/v1"
+
+// legacyPrefixes are the route groups that were served without a version
+// before /v1 existed. They remain reachable as deprecated aliases.
+var legacyPrefixes = []string{
+	"/auth",
+	"/records",
+	"/waitlist",
+	"/directory",
+	"/reports",
+	"/admin",
+	"/webhooks",
+	"/events",
+	"/notifications",
+}
+
+// InitAPIRouter registers the versioned API and its deprecated unversioned
+// aliases.
+func InitAPIRouter(app *fiber.App, config *config.Config, broker *stream.Broker) {
+	app.Use(middleware.DeprecatedAliasMiddleware(apiVersion, legacyPrefixes...))
+
+	api := app.Group(apiVersion)
+
+	InitAuthRouter(api, config)
+	InitRecordRouter(api, config)
+	InitWaitlistRouter(api, config)
+	InitDirectoryRouter(api, config)
+	InitReportRouter(api, config)
+	InitAdminRouter(api, config)
+	InitWebhookRouter(api, config)
+	InitEventRouter(api, config, broker)
+	InitNotificationRouter(api, config)
+}
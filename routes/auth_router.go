@@ -4,25 +4,27 @@ import (
 	"btwarch/config"
 	"btwarch/handlers"
 	"btwarch/middleware"
+	"btwarch/repositories"
 	"btwarch/services"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func InitAuthRouter(app *fiber.App) {
-	config := config.LoadConfig()
+func InitAuthRouter(router fiber.Router, config *config.Config) {
 	authHandler := handlers.NewAuthHandler(config)
 	authService := services.NewAuthService(
-		config.JWTSecret,
+		services.NewJWTKeys(config),
 		config.CookieDomain,
 		config.CookieSecure,
 		config.CookieSameSite,
 	)
 
-	authGroup := app.Group("/auth")
+	authGroup := router.Group("/auth")
+
+	authGroup.Use(middleware.RateLimitMiddleware(sharedRateLimitStore(config), "auth", config.RateLimitAuth))
 
 	authGroup.Get("/github", authHandler.InitiateGitHubAuth)
 	authGroup.Get("/github/callback", authHandler.GitHubCallback)
 	authGroup.Post("/logout", authHandler.Logout)
-	authGroup.Get("/me", middleware.AuthMiddleware(authService), authHandler.CheckAuth)
+	authGroup.Get("/me", middleware.AuthMiddleware(authService, repositories.NewUserRepository()), authHandler.CheckAuth)
 }
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/handlers"
+	"btwarch/middleware"
+	"btwarch/repositories"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitDirectoryRouter(router fiber.Router, config *config.Config) {
+	directoryHandler := handlers.NewDirectoryHandler(
+		config,
+		repositories.NewSubdomainClaimRepository(),
+	)
+
+	directoryGroup := router.Group("/directory")
+
+	directoryGroup.Use(middleware.RateLimitMiddleware(sharedRateLimitStore(config), "directory", config.RateLimitDirectory))
+
+	directoryGroup.Get("/", directoryHandler.ListDirectory)
+}
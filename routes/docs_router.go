@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"btwarch/logging"
+	"btwarch/openapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitDocsRouter(app *fiber.App) {
+	spec, err := openapi.JSON()
+	if err != nil {
+		logging.Fatal("Failed to load the OpenAPI document", "error", err)
+	}
+
+	app.Get("/openapi.json", func(ctx *fiber.Ctx) error {
+		ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return ctx.Send(spec)
+	})
+	csp := openapi.DocsContentSecurityPolicy()
+	app.Get("/docs", func(ctx *fiber.Ctx) error {
+		ctx.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		ctx.Set(fiber.HeaderContentSecurityPolicy, csp)
+		return ctx.Send(openapi.DocsPage)
+	})
+}
@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/handlers"
+	"btwarch/middleware"
+	"btwarch/repositories"
+	"btwarch/services"
+	"btwarch/stream"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// InitEventRouter takes the broker from the caller, since the same broker has
+// to be fed by the event relay.
+func InitEventRouter(router fiber.Router, config *config.Config, broker *stream.Broker) {
+	eventHandler := handlers.NewEventHandler(broker, config.EventsHeartbeatInterval)
+	authService := services.NewAuthService(
+		services.NewJWTKeys(config),
+		config.CookieDomain,
+		config.CookieSecure,
+		config.CookieSameSite,
+	)
+
+	eventGroup := router.Group("/events")
+
+	eventGroup.Use(middleware.AuthMiddleware(authService, repositories.NewUserRepository()))
+
+	eventGroup.Get("/", eventHandler.Stream)
+}
@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/database"
+	"btwarch/handlers"
+	"btwarch/health"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitHealthRouter(app *fiber.App, config *config.Config) {
+	healthHandler := handlers.NewHealthHandler(health.NewChecker(
+		config.HealthCheckTimeout,
+		config.HealthCacheTTL,
+		health.DatabaseCheck(database.DB),
+		health.MigrationsCheck(database.DB, database.MigrationsDir),
+		health.CloudflareCheck(config),
+	))
+
+	app.Get("/livez", healthHandler.Livez)
+	app.Get("/readyz", healthHandler.Readyz)
+
+	// Kept for existing monitors; equivalent to /livez.
+	app.Get("/health", func(ctx *fiber.Ctx) error {
+		return ctx.JSON(fiber.Map{
+			"status": "healthy",
+		})
+	})
+}
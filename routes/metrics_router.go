@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/metrics"
+	"btwarch/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+func InitMetricsRouter(app *fiber.App, config *config.Config) {
+	if !config.MetricsEnabled {
+		return
+	}
+
+	app.Get("/metrics", middleware.MetricsAccessMiddleware(config), adaptor.HTTPHandler(metrics.Handler()))
+}
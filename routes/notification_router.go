@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/handlers"
+	"btwarch/middleware"
+	"btwarch/notifications"
+	"btwarch/repositories"
+	"btwarch/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitNotificationRouter(router fiber.Router, config *config.Config) {
+	userRepo := repositories.NewUserRepository()
+	notificationRepo := repositories.NewNotificationRepository()
+
+	var emailNotifier *notifications.EmailNotifier
+	if config.EmailEnabled() {
+		emailNotifier = notifications.NewEmailNotifier(config, userRepo, notificationRepo)
+	}
+
+	notificationHandler := handlers.NewNotificationHandler(config, userRepo, notificationRepo, emailNotifier)
+	authService := services.NewAuthService(
+		services.NewJWTKeys(config),
+		config.CookieDomain,
+		config.CookieSecure,
+		config.CookieSameSite,
+	)
+
+	rateLimit := middleware.RateLimitMiddleware(sharedRateLimitStore(config), "notifications", config.RateLimitNotifications)
+
+	// Verification links are opened from the email, possibly without a session.
+	router.Get("/notifications/email/verify", rateLimit, notificationHandler.VerifyEmail)
+
+	notificationGroup := router.Group("/notifications")
+
+	notificationGroup.Use(middleware.AuthMiddleware(authService, userRepo))
+	notificationGroup.Use(rateLimit)
+
+	notificationGroup.Get("/preferences", notificationHandler.GetPreferences)
+	notificationGroup.Put("/preferences", notificationHandler.UpdatePreferences)
+	notificationGroup.Put("/email", notificationHandler.SetEmail)
+	notificationGroup.Delete("/email", notificationHandler.DeleteEmail)
+}
@@ -0,0 +1,459 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/openapi"
+	"btwarch/stream"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var specMethods = []string{
+	fiber.MethodGet,
+	fiber.MethodPut,
+	fiber.MethodPost,
+	fiber.MethodDelete,
+	fiber.MethodPatch,
+}
+
+var (
+	fiberParam    = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+	specParam     = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+	handlerMethod = regexp.MustCompile(`\.\(\*(\w+)\)\.(\w+)-fm$`)
+	jsonTag       = regexp.MustCompile(`json:"([^",]*)`)
+)
+
+type specDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Parameters map[string]specParameter `json:"parameters"`
+		Schemas    struct {
+			ErrorCode struct {
+				Enum []string `json:"enum"`
+			} `json:"ErrorCode"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+type specParameter struct {
+	Ref  string `json:"$ref"`
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+type specOperation struct {
+	Parameters []specParameter `json:"parameters"`
+}
+
+func loadSpec(t *testing.T) specDocument {
+	t.Helper()
+
+	data, err := openapi.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc specDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("error decoding OpenAPI document: %v", err)
+	}
+	return doc
+}
+
+// newTestApp registers every route the server registers, without touching
+// the database.
+func newTestApp() *fiber.App {
+	cfg := &config.Config{
+		ParentDomain:   "btwarch.me",
+		MetricsEnabled: true,
+	}
+
+	app := fiber.New()
+	InitHealthRouter(app, cfg)
+	InitMetricsRouter(app, cfg)
+	InitDocsRouter(app)
+	InitAPIRouter(app, cfg, stream.NewBroker())
+	return app
+}
+
+// specPath converts a Fiber route path to the OpenAPI path template.
+func specPath(path string) string {
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return fiberParam.ReplaceAllString(path, "{$1}")
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	doc := loadSpec(t)
+
+	registered := make(map[string]bool)
+	for _, route := range newTestApp().GetRoutes(true) {
+		if !slices.Contains(specMethods, route.Method) {
+			continue
+		}
+		registered[route.Method+" "+specPath(route.Path)] = true
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method := range item {
+			method = strings.ToUpper(method)
+			if slices.Contains(specMethods, method) {
+				documented[method+" "+path] = true
+			}
+		}
+	}
+
+	for _, op := range sortedKeys(registered) {
+		if !documented[op] {
+			t.Errorf("%s is served but not documented", op)
+		}
+	}
+	for _, op := range sortedKeys(documented) {
+		if !registered[op] {
+			t.Errorf("%s is documented but not served", op)
+		}
+	}
+}
+
+func TestSpecPathParameters(t *testing.T) {
+	doc := loadSpec(t)
+
+	for path, item := range doc.Paths {
+		var common []specParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &common); err != nil {
+				t.Fatalf("%s: error decoding parameters: %v", path, err)
+			}
+		}
+
+		var want []string
+		for _, match := range specParam.FindAllStringSubmatch(path, -1) {
+			want = append(want, match[1])
+		}
+		sort.Strings(want)
+
+		for method, raw := range item {
+			if !slices.Contains(specMethods, strings.ToUpper(method)) {
+				continue
+			}
+			var op specOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				t.Fatalf("%s %s: error decoding operation: %v", method, path, err)
+			}
+
+			var got []string
+			for _, param := range append(common, op.Parameters...) {
+				param = resolveParameter(t, doc, param)
+				if param.In == "path" {
+					got = append(got, param.Name)
+				}
+			}
+			sort.Strings(got)
+
+			if !slices.Equal(got, want) {
+				t.Errorf("%s %s: path parameters are %v, want %v", strings.ToUpper(method), path, got, want)
+			}
+		}
+	}
+}
+
+func resolveParameter(t *testing.T, doc specDocument, param specParameter) specParameter {
+	t.Helper()
+
+	if param.Ref == "" {
+		return param
+	}
+	name := strings.TrimPrefix(param.Ref, "#/components/parameters/")
+	resolved, ok := doc.Components.Parameters[name]
+	if !ok {
+		t.Fatalf("unknown parameter %s", param.Ref)
+	}
+	return resolved
+}
+
+// TestSpecErrorCodes checks that the ErrorCode schema lists exactly the codes
+// declared in the problem package.
+func TestSpecErrorCodes(t *testing.T) {
+	doc := loadSpec(t)
+
+	file, err := parser.ParseFile(token.NewFileSet(), "../problem/codes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var declared []string
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok || len(spec.Values) != len(spec.Names) {
+			return true
+		}
+		for i, name := range spec.Names {
+			lit, ok := spec.Values[i].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING || !strings.HasPrefix(name.Name, "Code") {
+				continue
+			}
+			code, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			declared = append(declared, code)
+		}
+		return true
+	})
+	if len(declared) == 0 {
+		t.Fatal("no codes found in problem/codes.go")
+	}
+
+	documented := slices.Clone(doc.Components.Schemas.ErrorCode.Enum)
+	sort.Strings(declared)
+	sort.Strings(documented)
+
+	for _, code := range declared {
+		if _, found := slices.BinarySearch(documented, code); !found {
+			t.Errorf("error code %q is not documented", code)
+		}
+	}
+	for _, code := range documented {
+		if _, found := slices.BinarySearch(declared, code); !found {
+			t.Errorf("documented error code %q does not exist", code)
+		}
+	}
+}
+
+func TestDocsRoutes(t *testing.T) {
+	app := newTestApp()
+
+	for path, contentType := range map[string]string{
+		"/openapi.json": fiber.MIMEApplicationJSON,
+		"/docs":         fiber.MIMETextHTMLCharsetUTF8,
+	} {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("GET %s: status %d, want %d", path, resp.StatusCode, fiber.StatusOK)
+		}
+		if got := resp.Header.Get(fiber.HeaderContentType); got != contentType {
+			t.Errorf("GET %s: content type %q, want %q", path, got, contentType)
+		}
+	}
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/docs", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csp := resp.Header.Get(fiber.HeaderContentSecurityPolicy); !strings.Contains(csp, "script-src ") || !strings.Contains(csp, "'sha256-") {
+		t.Errorf("GET /docs: Content-Security-Policy %q does not pin the page's scripts", csp)
+	}
+}
+
+// TestSpecRequestBodies checks that every operation documents exactly the
+// JSON fields its handler reads from the request body.
+func TestSpecRequestBodies(t *testing.T) {
+	data, err := openapi.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("error decoding OpenAPI document: %v", err)
+	}
+	paths, _ := doc["paths"].(map[string]any)
+
+	bodies := handlerBodies(t)
+	checked := make(map[string]bool)
+	for _, route := range newTestApp().GetRoutes(true) {
+		op := route.Method + " " + specPath(route.Path)
+		if !slices.Contains(specMethods, route.Method) || checked[op] || len(route.Handlers) == 0 {
+			continue
+		}
+		checked[op] = true
+
+		var handler string
+		name := runtime.FuncForPC(reflect.ValueOf(route.Handlers[len(route.Handlers)-1]).Pointer()).Name()
+		if match := handlerMethod.FindStringSubmatch(name); match != nil {
+			handler = match[1] + "." + match[2]
+		}
+		fields, parsesBody := bodies[handler]
+
+		item, _ := paths[specPath(route.Path)].(map[string]any)
+		operation, _ := item[strings.ToLower(route.Method)].(map[string]any)
+		requestBody, documented := operation["requestBody"]
+
+		switch {
+		case parsesBody && !documented:
+			t.Errorf("%s: %s reads a request body that is not documented", op, handler)
+		case documented && !parsesBody:
+			t.Errorf("%s: a request body is documented but %s does not read one", op, handlerOrRoute(handler))
+		case documented:
+			body, _ := resolveRef(t, doc, requestBody).(map[string]any)
+			content, _ := body["content"].(map[string]any)
+			media, _ := content[fiber.MIMEApplicationJSON].(map[string]any)
+			properties := schemaProperties(t, doc, media["schema"])
+
+			for _, field := range sortedKeys(fields) {
+				if !properties[field] {
+					t.Errorf("%s: %s reads %q, which is not documented", op, handler, field)
+				}
+			}
+			for _, field := range sortedKeys(properties) {
+				if !fields[field] {
+					t.Errorf("%s: %q is documented but %s does not read it", op, field, handler)
+				}
+			}
+		}
+	}
+}
+
+func handlerOrRoute(handler string) string {
+	if handler == "" {
+		return "the route"
+	}
+	return handler
+}
+
+// handlerBodies returns, by Handler.Method, the JSON fields of the body
+// struct each handler in the handlers package parses the request into.
+func handlerBodies(t *testing.T) map[string]map[string]bool {
+	t.Helper()
+
+	files, err := filepath.Glob("../handlers/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := make(map[string]map[string]bool)
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Body == nil || len(fn.Recv.List) != 1 {
+				continue
+			}
+			star, ok := fn.Recv.List[0].Type.(*ast.StarExpr)
+			if !ok {
+				continue
+			}
+			receiver, ok := star.X.(*ast.Ident)
+			if !ok {
+				continue
+			}
+
+			ast.Inspect(fn.Body, func(node ast.Node) bool {
+				spec, ok := node.(*ast.ValueSpec)
+				if !ok || len(spec.Names) != 1 || spec.Names[0].Name != "body" {
+					return true
+				}
+				structType, ok := spec.Type.(*ast.StructType)
+				if !ok {
+					return true
+				}
+
+				fields := make(map[string]bool)
+				for _, field := range structType.Fields.List {
+					if field.Tag == nil {
+						continue
+					}
+					tag, err := strconv.Unquote(field.Tag.Value)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if match := jsonTag.FindStringSubmatch(tag); match != nil && match[1] != "-" && match[1] != "" {
+						fields[match[1]] = true
+					}
+				}
+				bodies[receiver.Name+"."+fn.Name.Name] = fields
+				return false
+			})
+		}
+	}
+	if len(bodies) == 0 {
+		t.Fatal("no request bodies found in handlers")
+	}
+	return bodies
+}
+
+// resolveRef follows a local $ref in node, if there is one.
+func resolveRef(t *testing.T, doc map[string]any, node any) any {
+	t.Helper()
+
+	object, ok := node.(map[string]any)
+	if !ok {
+		return node
+	}
+	ref, ok := object["$ref"].(string)
+	if !ok {
+		return node
+	}
+
+	var target any = doc
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		parent, ok := target.(map[string]any)
+		if !ok {
+			t.Fatalf("unresolvable reference %s", ref)
+		}
+		if target, ok = parent[key]; !ok {
+			t.Fatalf("unresolvable reference %s", ref)
+		}
+	}
+	return resolveRef(t, doc, target)
+}
+
+// schemaProperties returns the property names of an object schema,
+// including those of the schemas it combines with allOf.
+func schemaProperties(t *testing.T, doc map[string]any, node any) map[string]bool {
+	t.Helper()
+
+	schema, _ := resolveRef(t, doc, node).(map[string]any)
+	names := make(map[string]bool)
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		for name := range properties {
+			names[name] = true
+		}
+	}
+	if parts, ok := schema["allOf"].([]any); ok {
+		for _, part := range parts {
+			for name := range schemaProperties(t, doc, part) {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/logging"
+	"btwarch/ratelimit"
+	"sync"
+)
+
+var (
+	rateLimitStore     ratelimit.Store
+	rateLimitStoreOnce sync.Once
+)
+
+// sharedRateLimitStore returns the store shared by all route groups, so that
+// the memory backend keeps a single set of buckets.
+func sharedRateLimitStore(config *config.Config) ratelimit.Store {
+	rateLimitStoreOnce.Do(func() {
+		store, err := ratelimit.NewStore(config)
+		if err != nil {
+			logging.Fatal("Failed to create rate limit store", "error", err)
+		}
+		rateLimitStore = store
+	})
+	return rateLimitStore
+}
@@ -3,39 +3,63 @@ package routes
 import (
 	"btwarch/config"
 	"btwarch/handlers"
+	"btwarch/lifecycle"
+	"btwarch/logging"
 	"btwarch/middleware"
+	"btwarch/notifications"
+	"btwarch/policy"
 	"btwarch/repositories"
 	"btwarch/services"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func InitRecordRouter(app *fiber.App) {
-	config := config.LoadConfig()
+func InitRecordRouter(router fiber.Router, config *config.Config) {
+	recordRepo := repositories.NewRecordRepository(config)
+	subdomainClaimRepo := repositories.NewSubdomainClaimRepository()
+	waitlistRepo := repositories.NewWaitlistRepository()
+	notifier := notifications.NewNotifier(config)
+	targetPolicy, err := policy.NewDefaultTargetPolicy(config, recordRepo)
+	if err != nil {
+		logging.Fatal("Failed to load record target policy", "error", err)
+	}
 	recordHandler := handlers.NewRecordHandler(
-		repositories.NewRecordRepository(),
-		repositories.NewSubdomainClaimRepository(),
+		config,
+		recordRepo,
+		subdomainClaimRepo,
+		waitlistRepo,
+		repositories.NewReportRepository(),
+		lifecycle.NewClaimReleaser(config, subdomainClaimRepo, recordRepo, waitlistRepo, notifier),
+		targetPolicy,
+		notifier,
 	)
 	authService := services.NewAuthService(
-		config.JWTSecret,
+		services.NewJWTKeys(config),
 		config.CookieDomain,
 		config.CookieSecure,
 		config.CookieSameSite,
 	)
 
-	recordGroup := app.Group("/records")
+	rateLimitStore := sharedRateLimitStore(config)
 
-	recordGroup.Use(middleware.AuthMiddleware(authService))
+	recordGroup := router.Group("/records")
+
+	recordGroup.Use(middleware.AuthMiddleware(authService, repositories.NewUserRepository()))
+
+	// Registered before the group limiter so that availability checks are only
+	// charged to their own bucket.
+	recordGroup.Post("/checkavailability", middleware.RateLimitMiddleware(rateLimitStore, "availability", config.RateLimitAvailability), recordHandler.CheckAvailability)
+
+	recordGroup.Use(middleware.RateLimitMiddleware(rateLimitStore, "records", config.RateLimitRecords))
 
 	recordGroup.Post("/", recordHandler.CreateRecord)
 	recordGroup.Post("/claim", recordHandler.ClaimSubdomain)
 	recordGroup.Get("/", recordHandler.GetRecords)
 	recordGroup.Get("/claim", recordHandler.GetSubdomainClaim)
 	recordGroup.Delete("/claim", recordHandler.DeleteSubdomain)
+	recordGroup.Put("/claim/visibility", recordHandler.UpdateSubdomainClaimVisibility)
+	recordGroup.Get("/quota", recordHandler.GetRecordQuota)
 	recordGroup.Get("/:id", recordHandler.GetRecord)
 	recordGroup.Put("/:id", recordHandler.UpdateRecord)
 	recordGroup.Delete("/:id", recordHandler.DeleteRecord)
-
-	recordGroup.Post("/checkavailability", recordHandler.CheckAvailability)
-
 }
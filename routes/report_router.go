@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/handlers"
+	"btwarch/middleware"
+	"btwarch/repositories"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitReportRouter(router fiber.Router, config *config.Config) {
+	reportHandler := handlers.NewReportHandler(
+		config,
+		repositories.NewReportRepository(),
+		repositories.NewSubdomainClaimRepository(),
+	)
+
+	reportGroup := router.Group("/reports")
+
+	reportGroup.Post("/", middleware.RateLimitMiddleware(sharedRateLimitStore(config), "reports", config.RateLimitReports), reportHandler.CreateReport)
+}
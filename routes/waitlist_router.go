@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/handlers"
+	"btwarch/middleware"
+	"btwarch/repositories"
+	"btwarch/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitWaitlistRouter(router fiber.Router, config *config.Config) {
+	waitlistHandler := handlers.NewWaitlistHandler(
+		repositories.NewWaitlistRepository(),
+		repositories.NewSubdomainClaimRepository(),
+	)
+	authService := services.NewAuthService(
+		services.NewJWTKeys(config),
+		config.CookieDomain,
+		config.CookieSecure,
+		config.CookieSameSite,
+	)
+
+	waitlistGroup := router.Group("/waitlist")
+
+	waitlistGroup.Use(middleware.AuthMiddleware(authService, repositories.NewUserRepository()))
+	waitlistGroup.Use(middleware.RateLimitMiddleware(sharedRateLimitStore(config), "waitlist", config.RateLimitWaitlist))
+
+	waitlistGroup.Post("/", waitlistHandler.JoinWaitlist)
+	waitlistGroup.Get("/", waitlistHandler.GetWaitlist)
+	waitlistGroup.Delete("/:name", waitlistHandler.LeaveWaitlist)
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"btwarch/config"
+	"btwarch/handlers"
+	"btwarch/middleware"
+	"btwarch/repositories"
+	"btwarch/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitWebhookRouter(router fiber.Router, config *config.Config) {
+	webhookHandler := handlers.NewWebhookHandler(
+		config,
+		repositories.NewWebhookRepository(),
+	)
+	authService := services.NewAuthService(
+		services.NewJWTKeys(config),
+		config.CookieDomain,
+		config.CookieSecure,
+		config.CookieSameSite,
+	)
+
+	webhookGroup := router.Group("/webhooks")
+
+	webhookGroup.Use(middleware.AuthMiddleware(authService, repositories.NewUserRepository()))
+	webhookGroup.Use(middleware.RateLimitMiddleware(sharedRateLimitStore(config), "webhooks", config.RateLimitWebhooks))
+
+	webhookGroup.Post("/", webhookHandler.CreateWebhook)
+	webhookGroup.Get("/", webhookHandler.GetWebhooks)
+	webhookGroup.Put("/:id", webhookHandler.UpdateWebhook)
+	webhookGroup.Delete("/:id", webhookHandler.DeleteWebhook)
+	webhookGroup.Get("/:id/deliveries", webhookHandler.GetDeliveries)
+	webhookGroup.Post("/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+}
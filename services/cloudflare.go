@@ -1,49 +1,148 @@
 package services
 
 import (
-	"btwarch/config"
+	"btwarch/metrics"
+	"btwarch/tracing"
 	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/cloudflare/cloudflare-go/v4"
 	"github.com/cloudflare/cloudflare-go/v4/dns"
 	"github.com/cloudflare/cloudflare-go/v4/option"
 	"github.com/cloudflare/cloudflare-go/v4/zones"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type CloudflareService struct {
 	client *cloudflare.Client
+	zoneID string
 }
 
-func NewCloudflareService(apiToken string) (*CloudflareService, error) {
+// NewCloudflareService returns a client for the records of one zone whose API
+// calls each give up after timeout; zero leaves only the request context's
+// deadline.
+func NewCloudflareService(apiToken, zoneID string, timeout time.Duration) (*CloudflareService, error) {
 	if apiToken == "" {
 		return nil, fmt.Errorf("cloudflare api token is required")
 	}
+	if zoneID == "" {
+		return nil, fmt.Errorf("cloudflare zone id is required")
+	}
+
+	opts := []option.RequestOption{
+		option.WithAPIToken(apiToken),
+		option.WithMiddleware(observeCloudflareCall),
+	}
+	if timeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(timeout))
+	}
 
 	service := &CloudflareService{
-		client: cloudflare.NewClient(option.WithAPIToken(apiToken)),
+		client: cloudflare.NewClient(opts...),
+		zoneID: zoneID,
 	}
 
 	return service, nil
 }
 
-func (s *CloudflareService) AddTXTRecord(name string, content string) (*dns.RecordResponse, error) {
-	cfg := config.LoadConfig()
-	if cfg.CloudFlareZoneId == "" {
-		return nil, fmt.Errorf("cloudflare zone id is required")
+// observeCloudflareCall records every API request, including retries, in the
+// Cloudflare call metrics, traces it and logs it with the request's context.
+// Responses with an error status count as errors.
+func observeCloudflareCall(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	operation := cloudflareOperation(req)
+	ctx, span := tracing.Start(req.Context(), "cloudflare "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("cloudflare.operation", operation),
+			attribute.String("http.request.method", req.Method),
+		),
+	)
+
+	start := time.Now()
+	resp, err := next(req.WithContext(ctx))
+	duration := time.Since(start)
+
+	attrs := []any{"operation", operation, "duration_ms", float64(duration.Microseconds()) / 1000}
+	if resp != nil {
+		attrs = append(attrs, "status", resp.StatusCode, "cf_ray", resp.Header.Get("Cf-Ray"))
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+
+	callErr := err
+	if callErr == nil && resp.StatusCode >= 400 {
+		callErr = fmt.Errorf("cloudflare responded with %s", resp.Status)
 	}
+	metrics.ObserveCloudflareCall(operation, duration, callErr)
+	tracing.End(span, callErr)
 
-	ctx := context.Background()
+	if callErr != nil {
+		slog.WarnContext(req.Context(), "Cloudflare API call failed", append(attrs, "error", callErr)...)
+	} else {
+		slog.DebugContext(req.Context(), "Cloudflare API call", attrs...)
+	}
+
+	return resp, err
+}
 
+// cloudflareOperation names a request after the resource and action, e.g.
+// "dns_records.update" for PUT /zones/{zone}/dns_records/{id}.
+func cloudflareOperation(req *http.Request) string {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	resource := "other"
+	hasID := false
+	for i, part := range parts {
+		if part == "zones" || part == "dns_records" {
+			resource = part
+			hasID = i+1 < len(parts)
+		}
+	}
+
+	action := strings.ToLower(req.Method)
+	switch req.Method {
+	case http.MethodGet:
+		action = "list"
+		if hasID {
+			action = "get"
+		}
+	case http.MethodPost:
+		action = "create"
+	case http.MethodPut, http.MethodPatch:
+		action = "update"
+	case http.MethodDelete:
+		action = "delete"
+	}
+
+	return resource + "." + action
+}
+
+// Ping checks that the API is reachable and the token can read the zone.
+func (s *CloudflareService) Ping(ctx context.Context) error {
 	_, err := s.client.Zones.Get(ctx, zones.ZoneGetParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get cloudflare zone: %w", err)
+	}
+
+	return nil
+}
+
+func (s *CloudflareService) AddTXTRecord(ctx context.Context, name string, content string) (*dns.RecordResponse, error) {
+	_, err := s.client.Zones.Get(ctx, zones.ZoneGetParams{
+		ZoneID: cloudflare.F(s.zoneID),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cloudflare zone: %w", err)
 	}
 
 	record, err := s.client.DNS.Records.New(ctx, dns.RecordNewParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 		Body: dns.RecordNewParamsBody{
 			Type:    cloudflare.F(dns.RecordNewParamsBodyTypeTXT),
 			Name:    cloudflare.F(name),
@@ -58,23 +157,16 @@ func (s *CloudflareService) AddTXTRecord(name string, content string) (*dns.Reco
 	return record, nil
 }
 
-func (s *CloudflareService) AddARecord(name string, content string) (*dns.RecordResponse, error) {
-	cfg := config.LoadConfig()
-	if cfg.CloudFlareZoneId == "" {
-		return nil, fmt.Errorf("cloudflare zone id is required")
-	}
-
-	ctx := context.Background()
-
+func (s *CloudflareService) AddARecord(ctx context.Context, name string, content string) (*dns.RecordResponse, error) {
 	_, err := s.client.Zones.Get(ctx, zones.ZoneGetParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cloudflare zone: %w", err)
 	}
 
 	record, err := s.client.DNS.Records.New(ctx, dns.RecordNewParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 		Body: dns.RecordNewParamsBody{
 			Type:    cloudflare.F(dns.RecordNewParamsBodyTypeA),
 			Name:    cloudflare.F(name),
@@ -89,23 +181,16 @@ func (s *CloudflareService) AddARecord(name string, content string) (*dns.Record
 	return record, nil
 }
 
-func (s *CloudflareService) AddAAAARecord(name string, content string) (*dns.RecordResponse, error) {
-	cfg := config.LoadConfig()
-	if cfg.CloudFlareZoneId == "" {
-		return nil, fmt.Errorf("cloudflare zone id is required")
-	}
-
-	ctx := context.Background()
-
+func (s *CloudflareService) AddAAAARecord(ctx context.Context, name string, content string) (*dns.RecordResponse, error) {
 	_, err := s.client.Zones.Get(ctx, zones.ZoneGetParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cloudflare zone: %w", err)
 	}
 
 	record, err := s.client.DNS.Records.New(ctx, dns.RecordNewParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 		Body: dns.RecordNewParamsBody{
 			Type:    cloudflare.F(dns.RecordNewParamsBodyTypeAAAA),
 			Name:    cloudflare.F(name),
@@ -120,23 +205,16 @@ func (s *CloudflareService) AddAAAARecord(name string, content string) (*dns.Rec
 	return record, nil
 }
 
-func (s *CloudflareService) AddCNAMERecord(name string, content string) (*dns.RecordResponse, error) {
-	cfg := config.LoadConfig()
-	if cfg.CloudFlareZoneId == "" {
-		return nil, fmt.Errorf("cloudflare zone id is required")
-	}
-
-	ctx := context.Background()
-
+func (s *CloudflareService) AddCNAMERecord(ctx context.Context, name string, content string) (*dns.RecordResponse, error) {
 	_, err := s.client.Zones.Get(ctx, zones.ZoneGetParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cloudflare zone: %w", err)
 	}
 
 	record, err := s.client.DNS.Records.New(ctx, dns.RecordNewParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 		Body: dns.RecordNewParamsBody{
 			Type:    cloudflare.F(dns.RecordNewParamsBodyTypeCNAME),
 			Name:    cloudflare.F(name),
@@ -151,27 +229,17 @@ func (s *CloudflareService) AddCNAMERecord(name string, content string) (*dns.Re
 	return record, nil
 }
 
-func (s *CloudflareService) DeleteRecordByID(recordID string) (*dns.RecordDeleteResponse, error) {
-	cfg := config.LoadConfig()
-	if cfg.CloudFlareZoneId == "" {
-		return nil, fmt.Errorf("cloudflare zone id is required")
-	}
-	ctx := context.Background()
-	record, err := s.client.DNS.Records.Delete(ctx, recordID, dns.RecordDeleteParams{ZoneID: cloudflare.F(cfg.CloudFlareZoneId)})
+func (s *CloudflareService) DeleteRecordByID(ctx context.Context, recordID string) (*dns.RecordDeleteResponse, error) {
+	record, err := s.client.DNS.Records.Delete(ctx, recordID, dns.RecordDeleteParams{ZoneID: cloudflare.F(s.zoneID)})
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete record: %w", err)
 	}
 	return record, nil
 }
 
-func (s *CloudflareService) UpdateARecord(recordID string, name string, content string) (*dns.RecordResponse, error) {
-	cfg := config.LoadConfig()
-	if cfg.CloudFlareZoneId == "" {
-		return nil, fmt.Errorf("cloudflare zone id is required")
-	}
-	ctx := context.Background()
+func (s *CloudflareService) UpdateARecord(ctx context.Context, recordID string, name string, content string) (*dns.RecordResponse, error) {
 	record, err := s.client.DNS.Records.Update(ctx, recordID, dns.RecordUpdateParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 		Body: dns.RecordUpdateParamsBody{
 			Type:    cloudflare.F(dns.RecordUpdateParamsBodyTypeA),
 			Name:    cloudflare.F(name),
@@ -184,14 +252,9 @@ func (s *CloudflareService) UpdateARecord(recordID string, name string, content
 	return record, nil
 }
 
-func (s *CloudflareService) UpdateAAAARecord(recordID string, name string, content string) (*dns.RecordResponse, error) {
-	cfg := config.LoadConfig()
-	if cfg.CloudFlareZoneId == "" {
-		return nil, fmt.Errorf("cloudflare zone id is required")
-	}
-	ctx := context.Background()
+func (s *CloudflareService) UpdateAAAARecord(ctx context.Context, recordID string, name string, content string) (*dns.RecordResponse, error) {
 	record, err := s.client.DNS.Records.Update(ctx, recordID, dns.RecordUpdateParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 		Body: dns.RecordUpdateParamsBody{
 			Type:    cloudflare.F(dns.RecordUpdateParamsBodyTypeAAAA),
 			Name:    cloudflare.F(name),
@@ -204,14 +267,9 @@ func (s *CloudflareService) UpdateAAAARecord(recordID string, name string, conte
 	return record, nil
 }
 
-func (s *CloudflareService) UpdateCNAMERecord(recordID string, name string, content string) (*dns.RecordResponse, error) {
-	cfg := config.LoadConfig()
-	if cfg.CloudFlareZoneId == "" {
-		return nil, fmt.Errorf("cloudflare zone id is required")
-	}
-	ctx := context.Background()
+func (s *CloudflareService) UpdateCNAMERecord(ctx context.Context, recordID string, name string, content string) (*dns.RecordResponse, error) {
 	record, err := s.client.DNS.Records.Update(ctx, recordID, dns.RecordUpdateParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 		Body: dns.RecordUpdateParamsBody{
 			Type:    cloudflare.F(dns.RecordUpdateParamsBodyTypeCNAME),
 			Name:    cloudflare.F(name),
@@ -224,14 +282,9 @@ func (s *CloudflareService) UpdateCNAMERecord(recordID string, name string, cont
 	return record, nil
 }
 
-func (s *CloudflareService) UpdateTXTRecord(recordID string, name string, content string) (*dns.RecordResponse, error) {
-	cfg := config.LoadConfig()
-	if cfg.CloudFlareZoneId == "" {
-		return nil, fmt.Errorf("cloudflare zone id is required")
-	}
-	ctx := context.Background()
+func (s *CloudflareService) UpdateTXTRecord(ctx context.Context, recordID string, name string, content string) (*dns.RecordResponse, error) {
 	record, err := s.client.DNS.Records.Update(ctx, recordID, dns.RecordUpdateParams{
-		ZoneID: cloudflare.F(cfg.CloudFlareZoneId),
+		ZoneID: cloudflare.F(s.zoneID),
 		Body: dns.RecordUpdateParamsBody{
 			Type:    cloudflare.F(dns.RecordUpdateParamsBodyTypeTXT),
 			Name:    cloudflare.F(name),
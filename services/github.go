@@ -1,17 +1,23 @@
 package services
 
 import (
+	"btwarch/config"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
 )
 
 type GitHubService struct {
-	config *oauth2.Config
+	config       *oauth2.Config
+	clientSecret *config.Secret
+	httpClient   *http.Client
 }
 
 type GitHubUser struct {
@@ -21,36 +27,66 @@ type GitHubUser struct {
 	AvatarURL string `json:"avatar_url"`
 }
 
-func NewGitHubService(clientID, clientSecret, redirectURL string) *GitHubService {
-	config := &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		RedirectURL:  redirectURL,
-		Scopes:       []string{"user:email"},
-		Endpoint:     github.Endpoint,
+// NewGitHubService returns a client for the OAuth app. The client secret is
+// read on every code exchange, so a rotated secret is used straight away.
+func NewGitHubService(clientID string, clientSecret *config.Secret, redirectURL string, timeout time.Duration) *GitHubService {
+	oauthConfig := &oauth2.Config{
+		ClientID:    clientID,
+		RedirectURL: redirectURL,
+		Scopes:      []string{"user:email"},
+		Endpoint:    github.Endpoint,
 	}
 
-	return &GitHubService{config: config}
+	// Requests made through the traced client show up as spans of the login
+	// request that made them. The timeout covers each call to GitHub.
+	httpClient := &http.Client{
+		Timeout: timeout,
+		Transport: otelhttp.NewTransport(http.DefaultTransport,
+			otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+				return "github " + req.Method + " " + req.URL.Path
+			}),
+		),
+	}
+
+	return &GitHubService{config: oauthConfig, clientSecret: clientSecret, httpClient: httpClient}
+}
+
+// oauthConfig returns the OAuth app configuration with the current secret.
+func (g *GitHubService) oauthConfig() *oauth2.Config {
+	oauthConfig := *g.config
+	oauthConfig.ClientSecret = g.clientSecret.Value()
+	return &oauthConfig
 }
 
 func (g *GitHubService) GetAuthURL(state string) string {
 	return g.config.AuthCodeURL(state)
 }
 
-func (g *GitHubService) ExchangeCode(code string) (*oauth2.Token, error) {
-	token, err := g.config.Exchange(context.Background(), code)
+func (g *GitHubService) ExchangeCode(ctx context.Context, code string) (*oauth2.Token, error) {
+	token, err := g.oauthConfig().Exchange(g.clientContext(ctx), code)
 	if err != nil {
-		return nil, fmt.Errorf("failed to exchange code for token: %v", err)
+		// Without an RFC 6749 error code the message carries the raw response
+		// body, which is not fit for the logs.
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "" && retrieveErr.Response != nil {
+			return nil, fmt.Errorf("failed to exchange code for token: GitHub returned status %s", retrieveErr.Response.Status)
+		}
+		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
 	}
 	return token, nil
 }
 
-func (g *GitHubService) GetUserInfo(token *oauth2.Token) (*GitHubUser, error) {
-	client := g.config.Client(context.Background(), token)
+func (g *GitHubService) GetUserInfo(ctx context.Context, token *oauth2.Token) (*GitHubUser, error) {
+	client := g.config.Client(g.clientContext(ctx), token)
 
-	resp, err := client.Get("https://api.github.com/user")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/user", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user info: %v", err)
+		return nil, fmt.Errorf("failed to build user info request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -60,8 +96,13 @@ func (g *GitHubService) GetUserInfo(token *oauth2.Token) (*GitHubUser, error) {
 
 	var user GitHubUser
 	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return nil, fmt.Errorf("failed to decode user response: %v", err)
+		return nil, fmt.Errorf("failed to decode user response: %w", err)
 	}
 
 	return &user, nil
 }
+
+// clientContext makes the oauth2 package use the traced HTTP client.
+func (g *GitHubService) clientContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, g.httpClient)
+}
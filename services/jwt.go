@@ -1,6 +1,9 @@
 package services
 
 import (
+	"btwarch/config"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -9,7 +12,7 @@ import (
 )
 
 type AuthService struct {
-	secretKey      []byte
+	keys           *JWTKeys
 	cookieDomain   string
 	cookieSecure   bool
 	cookieSameSite string
@@ -22,9 +25,65 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(secretKey string, cookieDomain string, cookieSecure bool, cookieSameSite string) *AuthService {
+// JWTKeys are the keys tokens are signed and checked with. Each key is
+// identified in the token header by a kid derived from it, so a token signed
+// before a rotation is checked with the key that signed it.
+type JWTKeys struct {
+	current        *config.Secret
+	previous       *config.Secret
+	rotationWindow time.Duration
+}
+
+func NewJWTKeys(config *config.Config) *JWTKeys {
+	return &JWTKeys{
+		current:        config.JWTSecret,
+		previous:       config.JWTPreviousSecret,
+		rotationWindow: config.JWTRotationWindow,
+	}
+}
+
+// signingKey returns the current key and its kid.
+func (k *JWTKeys) signingKey() ([]byte, string) {
+	key := []byte(k.current.Value())
+	return key, keyID(key)
+}
+
+// verificationKeys returns the accepted keys: the current key, the
+// configured previous key, and keys replaced by reloads within the rotation
+// window. A token with a kid is checked with the key it names only; a token
+// issued without one is checked with all of them.
+func (k *JWTKeys) verificationKeys(kid string) ([]jwt.VerificationKey, error) {
+	candidates := [][]byte{[]byte(k.current.Value())}
+	if previous := k.previous.Value(); previous != "" {
+		candidates = append(candidates, []byte(previous))
+	}
+	for _, replaced := range k.current.History() {
+		if time.Since(replaced.RotatedAt) < k.rotationWindow {
+			candidates = append(candidates, []byte(replaced.Value))
+		}
+	}
+
+	var keys []jwt.VerificationKey
+	for _, key := range candidates {
+		if kid == "" || keyID(key) == kid {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return keys, nil
+}
+
+// keyID identifies a key without revealing it.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func NewAuthService(keys *JWTKeys, cookieDomain string, cookieSecure bool, cookieSameSite string) *AuthService {
 	return &AuthService{
-		secretKey:      []byte(secretKey),
+		keys:           keys,
 		cookieDomain:   cookieDomain,
 		cookieSecure:   cookieSecure,
 		cookieSameSite: cookieSameSite,
@@ -43,8 +102,10 @@ func (a *AuthService) GenerateToken(userID string, username string, avatarURL st
 		},
 	}
 
+	key, kid := a.keys.signingKey()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(a.secretKey)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
 }
 
 func (a *AuthService) ValidateToken(tokenString string) (*Claims, error) {
@@ -52,7 +113,12 @@ func (a *AuthService) ValidateToken(tokenString string) (*Claims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return a.secretKey, nil
+		kid, _ := token.Header["kid"].(string)
+		keys, err := a.keys.verificationKeys(kid)
+		if err != nil {
+			return nil, err
+		}
+		return jwt.VerificationKeySet{Keys: keys}, nil
 	})
 
 	if err != nil {
@@ -89,6 +155,25 @@ func (a *AuthService) SetAuthCookie(c *fiber.Ctx, userID string, username string
 	return nil
 }
 
+// SetDeviceCookie stores the token that identifies this browser across
+// logins. It outlives the auth cookie and is kept on logout.
+func (a *AuthService) SetDeviceCookie(c *fiber.Ctx, token string) {
+	cookie := new(fiber.Cookie)
+	cookie.Name = "device_id"
+	cookie.Value = token
+	cookie.Expires = time.Now().Add(365 * 24 * time.Hour)
+	cookie.HTTPOnly = true
+	cookie.Secure = a.cookieSecure
+	cookie.SameSite = a.cookieSameSite
+	cookie.Path = "/"
+
+	if a.cookieDomain != "" {
+		cookie.Domain = a.cookieDomain
+	}
+
+	c.Cookie(cookie)
+}
+
 func (a *AuthService) ClearAuthCookie(c *fiber.Ctx) {
 	cookie := new(fiber.Cookie)
 	cookie.Name = "auth_token"
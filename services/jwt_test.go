@@ -0,0 +1,88 @@
+package services
+
+import (
+	"btwarch/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// rotatedConfig loads a config whose JWT_SECRET file held each of secrets in
+// turn, the last one being current.
+func rotatedConfig(t *testing.T, secrets ...string) *config.Config {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwt_secret")
+	if err := os.WriteFile(path, []byte(secrets[0]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_SECRET_FILE", path)
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range secrets[1:] {
+		if err := os.WriteFile(path, []byte(secret), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cfg.JWTSecret.Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg
+}
+
+func signedToken(t *testing.T, secret string, withKeyID bool) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID: "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if withKeyID {
+		token.Header["kid"] = keyID([]byte(secret))
+	}
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestValidateTokenAcrossRotations(t *testing.T) {
+	cfg := rotatedConfig(t, "first-secret", "second-secret", "third-secret")
+	auth := NewAuthService(NewJWTKeys(cfg), "", false, "lax")
+
+	for _, secret := range []string{"first-secret", "second-secret", "third-secret"} {
+		for _, withKeyID := range []bool{true, false} {
+			if _, err := auth.ValidateToken(signedToken(t, secret, withKeyID)); err != nil {
+				t.Errorf("token signed with %s (kid %v) rejected: %v", secret, withKeyID, err)
+			}
+		}
+	}
+
+	if _, err := auth.ValidateToken(signedToken(t, "unknown-secret", false)); err == nil {
+		t.Error("token signed with an unknown key accepted")
+	}
+}
+
+func TestValidateTokenOutsideRotationWindow(t *testing.T) {
+	cfg := rotatedConfig(t, "first-secret", "second-secret")
+	cfg.JWTRotationWindow = 0
+	auth := NewAuthService(NewJWTKeys(cfg), "", false, "lax")
+
+	for _, withKeyID := range []bool{true, false} {
+		if _, err := auth.ValidateToken(signedToken(t, "first-secret", withKeyID)); err == nil {
+			t.Errorf("token signed with a key replaced outside the window (kid %v) accepted", withKeyID)
+		}
+	}
+	if _, err := auth.ValidateToken(signedToken(t, "second-secret", false)); err != nil {
+		t.Errorf("token signed with the current key rejected: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"btwarch/database"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LivenessProber checks that a record points at something that answers HTTP
+// or HTTPS. Any HTTP response counts as alive; only connection-level failures
+// count as dead.
+type LivenessProber struct {
+	timeout      time.Duration
+	checkAddress func(ip string) error
+}
+
+// NewLivenessProber returns a prober that refuses, at dial time and after DNS
+// resolution, to connect to any address checkAddress rejects, so that records
+// pointing at loopback or internal addresses cannot make the scheduler probe
+// them.
+func NewLivenessProber(timeout time.Duration, checkAddress func(ip string) error) *LivenessProber {
+	return &LivenessProber{timeout: timeout, checkAddress: checkAddress}
+}
+
+// Probe requests the record's target, presenting hostname as the Host header
+// and TLS server name so virtual-hosted targets answer for the claimed name.
+func (p *LivenessProber) Probe(ctx context.Context, hostname string, record *database.Record) error {
+	var target string
+	switch record.RecordType {
+	case "A":
+		target = record.RecordValue
+	case "AAAA":
+		target = "[" + record.RecordValue + "]"
+	case "CNAME":
+		target = strings.TrimSuffix(record.RecordValue, ".")
+	default:
+		return fmt.Errorf("record type %s cannot prove liveness", record.RecordType)
+	}
+
+	dialer := &net.Dialer{
+		Timeout: p.timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return p.checkAddress(host)
+		},
+	}
+
+	client := &http.Client{
+		Timeout: p.timeout,
+		Transport: &http.Transport{
+			// Certificates are not validated: the probe only checks that
+			// something is listening, not that TLS is set up correctly.
+			TLSClientConfig:   &tls.Config{ServerName: hostname, InsecureSkipVerify: true},
+			DialContext:       dialer.DialContext,
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var errs []string
+	for _, scheme := range []string{"https", "http"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+target+"/", nil)
+		if err != nil {
+			return fmt.Errorf("failed to build probe request: %w", err)
+		}
+		req.Host = hostname
+		req.Header.Set("User-Agent", "btwarch-liveness-probe")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", scheme, err))
+			continue
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	return fmt.Errorf("%s %s did not respond: %s", record.RecordType, record.RecordValue, strings.Join(errs, "; "))
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpMessage is what the stand-in server received in one transaction.
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer runs a minimal SMTP server on a local port for one
+// session. rcptReply is the reply to RCPT TO, so that tests can have the
+// recipient rejected.
+func startSMTPServer(t *testing.T, rcptReply string) (port string, received <-chan smtpMessage) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		text := textproto.NewConn(conn)
+		var message smtpMessage
+		text.PrintfLine("220 localhost ESMTP test")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL":
+				message.from = arg
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				message.to = append(message.to, arg)
+				text.PrintfLine("%s", rcptReply)
+			case "DATA":
+				text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				// DotReader also turns CRLF line endings into LF.
+				data, err := io.ReadAll(text.DotReader())
+				if err != nil {
+					return
+				}
+				message.data = string(data)
+				messages <- message
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+
+	_, port, _ = net.SplitHostPort(listener.Addr().String())
+	return port, messages
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	port, received := startSMTPServer(t, "250 OK")
+	mailer := NewSMTPMailer("127.0.0.1", port, "", "", "btwarch <noreply@btwarch.me>", SMTPSecurityNone, 5*time.Second)
+
+	err := mailer.Send(EmailMessage{
+		To:       "user@example.com",
+		Subject:  "Confirm your btwarch.me notification email ✓",
+		TextBody: "Open the link:\nhttps://api.btwarch.me/verify",
+		HTMLBody: "<p>Open the <a href=\"https://api.btwarch.me/verify\">link</a></p>",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var message smtpMessage
+	select {
+	case message = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server received no message")
+	}
+
+	if message.from != "FROM:<noreply@btwarch.me>" {
+		t.Errorf("MAIL %s, want FROM:<noreply@btwarch.me>", message.from)
+	}
+	if len(message.to) != 1 || message.to[0] != "TO:<user@example.com>" {
+		t.Errorf("RCPT %v, want [TO:<user@example.com>]", message.to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(message.data))
+	if err != nil {
+		t.Fatalf("error parsing message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Confirm your btwarch.me notification email ✓" {
+		t.Errorf("Subject %q", subject)
+	}
+	if to := parsed.Header.Get("To"); to != "<user@example.com>" {
+		t.Errorf("To %q, want <user@example.com>", to)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type %q", parsed.Header.Get("Content-Type"))
+	}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	want := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", "Open the link:\nhttps://api.btwarch.me/verify"},
+		{"text/html; charset=utf-8", "<p>Open the <a href=\"https://api.btwarch.me/verify\">link</a></p>"},
+	}
+	for _, part := range want {
+		p, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("error reading %s part: %v", part.contentType, err)
+		}
+		if got := p.Header.Get("Content-Type"); got != part.contentType {
+			t.Errorf("part Content-Type %q, want %q", got, part.contentType)
+		}
+		body, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != part.body {
+			t.Errorf("%s part %q, want %q", part.contentType, body, part.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("unexpected extra part: %v", err)
+	}
+}
+
+func TestSMTPMailerRejectedRecipient(t *testing.T) {
+	port, _ := startSMTPServer(t, "550 No such user")
+	mailer := NewSMTPMailer("127.0.0.1", port, "", "", "noreply@btwarch.me", SMTPSecurityNone, 5*time.Second)
+
+	err := mailer.Send(EmailMessage{To: "nobody@example.com", Subject: "Test", TextBody: "text", HTMLBody: "<p>html</p>"})
+	if err == nil || !strings.Contains(err.Error(), "RCPT TO") {
+		t.Fatalf("Send: %v, want a RCPT TO error", err)
+	}
+}
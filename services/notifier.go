@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+const (
+	NotificationClaimCreated  = "claim.created"
+	NotificationClaimInactive = "claim.inactive"
+	NotificationClaimCooldown = "claim.cooldown"
+	NotificationClaimReleased = "claim.released"
+
+	NotificationWaitlistReserved = "waitlist.reserved"
+	NotificationWaitlistReleased = "waitlist.released"
+
+	NotificationRecordChanged = "record.changed"
+
+	NotificationNewDevice = "security.new_device"
+
+	NotificationUserSuspended   = "user.suspended"
+	NotificationUserUnsuspended = "user.unsuspended"
+	NotificationRecordDisabled  = "moderation.record_disabled"
+	NotificationClaimRevoked    = "moderation.claim_released"
+)
+
+type Notification struct {
+	UserID  uuid.UUID
+	Event   string
+	Subject string
+	Message string
+}
+
+// Notifier delivers user-facing notifications. Implementations must be safe
+// to call from background jobs.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	slog.InfoContext(ctx, "Notification", "event", notification.Event, "user_id", notification.UserID, "subject", notification.Subject)
+	return nil
+}
@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"btwarch/events"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxStreamsPerUser caps the open event streams of a single user.
+	MaxStreamsPerUser = 5
+
+	subscriptionBuffer = 64
+)
+
+var (
+	ErrTooManyStreams = errors.New("too many open event streams")
+	ErrBrokerClosed   = errors.New("event streams are shutting down")
+)
+
+// Subscription receives the events of one user until it is closed.
+type Subscription struct {
+	UserID uuid.UUID
+	Events chan events.Event
+}
+
+// Broker fans events out to the open streams of the user they belong to.
+// A subscriber that falls behind misses events instead of blocking the
+// publisher.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[uuid.UUID]map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: map[uuid.UUID]map[*Subscription]struct{}{},
+	}
+}
+
+func (b *Broker) Subscribe(userID uuid.UUID) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+
+	subscriptions := b.subscribers[userID]
+	if len(subscriptions) >= MaxStreamsPerUser {
+		return nil, ErrTooManyStreams
+	}
+	if subscriptions == nil {
+		subscriptions = map[*Subscription]struct{}{}
+		b.subscribers[userID] = subscriptions
+	}
+
+	sub := &Subscription{
+		UserID: userID,
+		Events: make(chan events.Event, subscriptionBuffer),
+	}
+	subscriptions[sub] = struct{}{}
+
+	return sub, nil
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscriptions := b.subscribers[sub.UserID]
+	if _, ok := subscriptions[sub]; !ok {
+		return
+	}
+
+	delete(subscriptions, sub)
+	if len(subscriptions) == 0 {
+		delete(b.subscribers, sub.UserID)
+	}
+	close(sub.Events)
+}
+
+func (b *Broker) Deliver(event events.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers[event.UserID] {
+		select {
+		case sub.Events <- event:
+		default:
+			slog.Warn("Dropped event for a slow stream", "event", event.Type, "user_id", event.UserID)
+		}
+	}
+}
+
+// Close ends every open stream and refuses new ones. Streams never finish on
+// their own, so this must happen before the server waits for requests to
+// drain.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for userID, subscriptions := range b.subscribers {
+		for sub := range subscriptions {
+			close(sub.Events)
+		}
+		delete(b.subscribers, userID)
+	}
+}
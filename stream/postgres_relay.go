@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"btwarch/database"
+	"btwarch/events"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	notifyChannel = "btwarch_events"
+
+	// Postgres rejects NOTIFY payloads of 8000 bytes or more.
+	maxNotifyPayload = 7999
+)
+
+// PostgresRelay sends events through NOTIFY and delivers everything it hears
+// on the channel, including its own notifications, to the local broker.
+type PostgresRelay struct {
+	broker   *Broker
+	listener *pq.Listener
+	done     chan struct{}
+}
+
+func NewPostgresRelay(databaseURL string, broker *Broker) (*PostgresRelay, error) {
+	listener := pq.NewListener(databaseURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("Event listener connection error", "error", err)
+		}
+	})
+
+	if err := listener.Listen(notifyChannel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("error listening on %s: %v", notifyChannel, err)
+	}
+
+	relay := &PostgresRelay{
+		broker:   broker,
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	go relay.listen()
+
+	return relay, nil
+}
+
+func (r *PostgresRelay) Publish(event events.Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Error encoding event", "event", event.Type, "error", err)
+		return
+	}
+
+	// Too large to relay: at least reach the streams on this instance.
+	if len(payload) > maxNotifyPayload {
+		slog.Warn("Event too large to relay, delivering locally", "event", event.Type, "bytes", len(payload))
+		r.broker.Deliver(event)
+		return
+	}
+
+	if _, err := database.DB.Exec("SELECT pg_notify($1, $2)", notifyChannel, string(payload)); err != nil {
+		slog.Error("Error relaying event", "event", event.Type, "error", err)
+		r.broker.Deliver(event)
+	}
+}
+
+func (r *PostgresRelay) listen() {
+	for {
+		select {
+		case <-r.done:
+			return
+		case notification, ok := <-r.listener.Notify:
+			if !ok {
+				return
+			}
+			// A nil notification follows a reconnect; anything sent while
+			// disconnected is lost.
+			if notification == nil {
+				continue
+			}
+
+			var event events.Event
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				slog.Error("Error decoding relayed event", "error", err)
+				continue
+			}
+			r.broker.Deliver(event)
+		case <-time.After(90 * time.Second):
+			go r.listener.Ping()
+		}
+	}
+}
+
+func (r *PostgresRelay) Close() error {
+	close(r.done)
+	return r.listener.Close()
+}
@@ -0,0 +1,40 @@
+package stream
+
+import (
+	"btwarch/config"
+	"btwarch/events"
+	"fmt"
+)
+
+// Relay carries published events to the broker of every instance that has
+// streams open.
+type Relay interface {
+	Publish(event events.Event)
+	Close() error
+}
+
+// NewRelay returns the relay selected by EVENTS_BACKEND. The memory relay only
+// reaches streams on this instance; the postgres relay uses LISTEN/NOTIFY to
+// reach all of them.
+func NewRelay(cfg *config.Config, broker *Broker) (Relay, error) {
+	switch cfg.EventsBackend {
+	case "", "memory":
+		return &memoryRelay{broker: broker}, nil
+	case "postgres":
+		return NewPostgresRelay(cfg.DatabaseURL, broker)
+	default:
+		return nil, fmt.Errorf("unknown events backend: %s", cfg.EventsBackend)
+	}
+}
+
+type memoryRelay struct {
+	broker *Broker
+}
+
+func (r *memoryRelay) Publish(event events.Event) {
+	r.broker.Deliver(event)
+}
+
+func (r *memoryRelay) Close() error {
+	return nil
+}
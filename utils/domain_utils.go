@@ -6,16 +6,15 @@ import (
 	"strings"
 )
 
-// ValidateSubdomainName validates if a subdomain name is valid for claiming
+// ValidateSubdomainName validates if a normalized (lowercase A-label) subdomain name is valid for claiming
 func ValidateSubdomainName(subdomainName string) error {
 	if subdomainName == "" {
 		return fmt.Errorf("subdomain name cannot be empty")
 	}
 
-	// Check if it contains only valid characters (alphanumeric and hyphens)
+	// Check if it contains only valid characters (lowercase alphanumeric and hyphens)
 	for _, char := range subdomainName {
-		if !((char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') ||
-			(char >= '0' && char <= '9') || char == '-') {
+		if !((char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') || char == '-') {
 			return fmt.Errorf("subdomain name can only contain letters, numbers, and hyphens")
 		}
 	}
@@ -34,8 +33,7 @@ func ValidateSubdomainName(subdomainName string) error {
 }
 
 // ValidateRecordName validates if a record name is valid for the given record type and user's subdomain
-func ValidateRecordName(recordName, recordType, userSubdomain string) error {
-	cfg := config.LoadConfig()
+func ValidateRecordName(cfg *config.Config, recordName, recordType, userSubdomain string) error {
 	parentDomain := cfg.ParentDomain
 
 	// Build the full subdomain name
@@ -55,8 +53,15 @@ func ValidateRecordName(recordName, recordType, userSubdomain string) error {
 			return nil
 		}
 
-		// Allow sub-labels under the user's subdomain
-		if strings.HasSuffix(recordName, "."+fullSubdomain) || strings.HasSuffix(recordName, "."+userSubdomain) {
+		// Allow sub-labels under the user's subdomain, up to the configured depth
+		prefix, ok := strings.CutSuffix(recordName, "."+fullSubdomain)
+		if !ok {
+			prefix, ok = strings.CutSuffix(recordName, "."+userSubdomain)
+		}
+		if ok {
+			if depth := len(strings.Split(prefix, ".")); cfg.RecordMaxLabelDepth > 0 && depth > cfg.RecordMaxLabelDepth {
+				return fmt.Errorf("TXT records can be at most %d labels below %s", cfg.RecordMaxLabelDepth, fullSubdomain)
+			}
 			return nil
 		}
 
@@ -72,24 +77,20 @@ func ValidateRecordName(recordName, recordType, userSubdomain string) error {
 }
 
 // GetFullSubdomainName returns the full subdomain name with parent domain
-func GetFullSubdomainName(subdomainName string) string {
-	cfg := config.LoadConfig()
-	return subdomainName + "." + cfg.ParentDomain
+func GetFullSubdomainName(subdomainName, parentDomain string) string {
+	return subdomainName + "." + parentDomain
 }
 
 // ExtractSubdomainFromRecordName extracts the subdomain name from a full record name
-func ExtractSubdomainFromRecordName(recordName string) string {
-	cfg := config.LoadConfig()
-	parentDomain := cfg.ParentDomain
+func ExtractSubdomainFromRecordName(recordName, parentDomain string) string {
 
 	// If record name ends with parent domain, extract the subdomain part
 	if strings.HasSuffix(recordName, "."+parentDomain) {
 		subdomain := strings.TrimSuffix(recordName, "."+parentDomain)
-		// Extract the first part (subdomain) from subdomain.something.xyz.com
+		// The claimed subdomain is the label right before the parent domain,
+		// e.g. "subdomain" in _acme-challenge.subdomain.btwarch.me
 		parts := strings.Split(subdomain, ".")
-		if len(parts) > 0 {
-			return parts[0]
-		}
+		return parts[len(parts)-1]
 	}
 
 	// If record name doesn't have parent domain, it might be just the subdomain
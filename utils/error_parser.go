@@ -17,16 +17,30 @@ func ExtractErrorMessage(err error) string {
 		return ""
 	}
 
+	if message, ok := CloudflareErrorMessage(err); ok {
+		return message
+	}
+
+	return err.Error()
+}
+
+// CloudflareErrorMessage returns the first message of the Cloudflare API error
+// response embedded in err, and false when err does not carry one.
+func CloudflareErrorMessage(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+
 	// Try to find JSON part inside the error string
 	if idx := strings.Index(err.Error(), "{"); idx != -1 {
 		jsonPart := err.Error()[idx:]
 		var cfErr CloudflareError
 		if json.Unmarshal([]byte(jsonPart), &cfErr) == nil {
-			if len(cfErr.Errors) > 0 {
-				return cfErr.Errors[0].Message
+			if len(cfErr.Errors) > 0 && cfErr.Errors[0].Message != "" {
+				return cfErr.Errors[0].Message, true
 			}
 		}
 	}
 
-	return err.Error()
+	return "", false
 }
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// subdomainProfile applies UTS #46 mapping (including case folding) followed by
+// IDNA2008 validation, bidi and joiner rules.
+var subdomainProfile = idna.New(
+	idna.MapForLookup(),
+	idna.Transitional(false),
+	idna.BidiRule(),
+	idna.CheckHyphens(true),
+	idna.CheckJoiners(true),
+	idna.StrictDomainName(true),
+	idna.ValidateLabels(true),
+)
+
+// allowedScriptSets lists the script combinations a single label may use, in
+// the spirit of the UTS #39 "highly restrictive" level. Any single script is
+// also allowed.
+var allowedScriptSets = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+// confusables maps characters that are commonly used to imitate Latin letters
+// to the letter they imitate.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'һ': 'h', 'і': 'i', 'ї': 'i', 'ј': 'j',
+	'к': 'k', 'ӏ': 'l', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'ԛ': 'q',
+	'ѕ': 's', 'т': 't', 'у': 'y', 'ү': 'y', 'х': 'x', 'ԝ': 'w', 'ь': 'b', 'ԁ': 'd',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'γ': 'y', 'ω': 'w',
+	// Armenian
+	'օ': 'o', 'ս': 'u', 'հ': 'h', 'ո': 'n', 'ց': 'g', 'զ': 'q',
+	// Latin look-alikes outside ASCII
+	'ı': 'i', 'ɑ': 'a', 'ɡ': 'g', 'ɩ': 'i', 'ʏ': 'y', 'ℓ': 'l',
+}
+
+// lookalikes folds ASCII characters that are mistaken for each other, such as
+// g00gle for google or paypa1 for paypal. It is applied after confusables, so
+// that e.g. a Cyrillic "о" ends up in the same class as "0" and "o". "i" and
+// "l" are kept apart, or ordinary pairs such as mail and mall would collide.
+var lookalikes = map[rune]rune{
+	'0': 'o', '1': 'l',
+}
+
+// NormalizeSubdomainName converts a user-supplied subdomain name, which may be
+// Unicode, to its canonical lowercase A-label and validates it.
+func NormalizeSubdomainName(subdomainName string) (string, error) {
+	subdomainName = strings.TrimSpace(subdomainName)
+	if subdomainName == "" {
+		return "", fmt.Errorf("subdomain name cannot be empty")
+	}
+
+	aLabel, err := subdomainProfile.ToASCII(subdomainName)
+	if err != nil {
+		return "", fmt.Errorf("subdomain name is not a valid internationalized name: %v", err)
+	}
+
+	if strings.Contains(aLabel, ".") {
+		return "", fmt.Errorf("subdomain name must be a single label")
+	}
+
+	if err := ValidateSubdomainName(aLabel); err != nil {
+		return "", err
+	}
+
+	if err := validateSingleScript(SubdomainDisplayName(aLabel)); err != nil {
+		return "", err
+	}
+
+	return aLabel, nil
+}
+
+// NormalizeRecordName lowercases a record name and converts any Unicode labels
+// to A-labels. ASCII labels are left as-is so names like _acme-challenge keep
+// working.
+func NormalizeRecordName(recordName string) (string, error) {
+	labels := strings.Split(strings.TrimSpace(recordName), ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			labels[i] = strings.ToLower(label)
+			continue
+		}
+
+		aLabel, err := subdomainProfile.ToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("record name label %q is not a valid internationalized name: %v", label, err)
+		}
+		labels[i] = aLabel
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// SubdomainDisplayName returns the Unicode U-label for a stored A-label. Names
+// that cannot be decoded are returned unchanged.
+func SubdomainDisplayName(aLabel string) string {
+	uLabel, err := idna.ToUnicode(aLabel)
+	if err != nil {
+		return aLabel
+	}
+	return uLabel
+}
+
+// SubdomainSkeleton reduces a name to a form in which confusable names
+// compare equal, e.g. a Cyrillic "аpple" and a Latin "apple", or "g00gle" and
+// "google". Migration 020 applies the lookalikes to stored skeletons; keep the
+// two in sync.
+func SubdomainSkeleton(aLabel string) string {
+	uLabel := SubdomainDisplayName(aLabel)
+
+	var b strings.Builder
+	for _, r := range uLabel {
+		if mapped, ok := confusables[r]; ok {
+			r = mapped
+		}
+		r = unicode.ToLower(r)
+		if mapped, ok := lookalikes[r]; ok {
+			r = mapped
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func validateSingleScript(uLabel string) error {
+	scripts := map[string]bool{}
+	for _, r := range uLabel {
+		if unicode.In(r, unicode.Common, unicode.Inherited) {
+			continue
+		}
+		for name, table := range unicode.Scripts {
+			if unicode.Is(table, r) {
+				scripts[name] = true
+				break
+			}
+		}
+	}
+
+	if len(scripts) <= 1 {
+		return nil
+	}
+
+	for _, set := range allowedScriptSets {
+		if containsAllScripts(set, scripts) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("subdomain name cannot mix characters from different scripts")
+}
+
+func containsAllScripts(set []string, scripts map[string]bool) bool {
+	for script := range scripts {
+		found := false
+		for _, allowed := range set {
+			if script == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxTXTLength is the longest TXT content Cloudflare accepts.
+	maxTXTLength = 2048
+	// txtChunkLength is the longest single character-string in a TXT record.
+	txtChunkLength = 255
+
+	ttlAutomatic = 1
+	minTTL       = 60
+	maxTTL       = 86400
+)
+
+// FieldError describes a problem with a single request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects the field errors of a request.
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + ": " + field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// RecordInput is a record as submitted by a user. ValidateRecordInput
+// replaces its value and TTL with their canonical forms.
+type RecordInput struct {
+	Type  string
+	Value string
+	TTL   int
+}
+
+// ValidateRecordInput checks the record type, value and TTL before anything is
+// written to the database or sent to Cloudflare. On success the value is
+// canonical: A and AAAA addresses in standard notation, CNAME targets as
+// lowercase A-label hostnames, and TXT content quoted and split into 255-byte
+// strings. A TTL of 0 means automatic.
+func ValidateRecordInput(input *RecordInput) error {
+	verr := &ValidationError{}
+
+	switch input.Type {
+	case "A":
+		addr, err := netip.ParseAddr(strings.TrimSpace(input.Value))
+		if err != nil || !addr.Is4() {
+			verr.add("record_value", "must be an IPv4 address such as 203.0.113.10")
+		} else {
+			input.Value = addr.String()
+		}
+	case "AAAA":
+		addr, err := netip.ParseAddr(strings.TrimSpace(input.Value))
+		if err != nil || !addr.Is6() || addr.Is4In6() || addr.Zone() != "" {
+			verr.add("record_value", "must be an IPv6 address such as 2001:db8::10")
+		} else {
+			input.Value = addr.String()
+		}
+	case "CNAME":
+		hostname, err := normalizeHostname(input.Value)
+		if err != nil {
+			verr.add("record_value", err.Error())
+		} else {
+			input.Value = hostname
+		}
+	case "TXT":
+		value, err := FormatTXTValue(input.Value)
+		if err != nil {
+			verr.add("record_value", err.Error())
+		} else {
+			input.Value = value
+		}
+	case "NS", "MX":
+		verr.add("record_type", "NS and MX records are not allowed")
+	default:
+		verr.add("record_type", "must be one of: A, AAAA, CNAME, TXT")
+	}
+
+	switch {
+	case input.TTL == 0:
+		input.TTL = ttlAutomatic
+	case input.TTL == ttlAutomatic:
+	case input.TTL < minTTL || input.TTL > maxTTL:
+		verr.add("ttl", fmt.Sprintf("must be 1 (automatic) or between %d and %d seconds", minTTL, maxTTL))
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// normalizeHostname validates a CNAME target as an RFC 1123 hostname with at
+// least two labels and returns it as a lowercase A-label without the trailing
+// dot.
+func normalizeHostname(value string) (string, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), ".")
+	if value == "" {
+		return "", fmt.Errorf("must be a hostname such as example.com")
+	}
+
+	hostname, err := NormalizeRecordName(value)
+	if err != nil {
+		return "", err
+	}
+
+	if len(hostname) > 253 {
+		return "", fmt.Errorf("hostname must be at most 253 characters")
+	}
+
+	labels := strings.Split(hostname, ".")
+	if len(labels) < 2 {
+		return "", fmt.Errorf("must be a fully qualified hostname such as example.com")
+	}
+
+	for _, label := range labels {
+		if label == "" {
+			return "", fmt.Errorf("hostname cannot contain empty labels")
+		}
+		if len(label) > 63 {
+			return "", fmt.Errorf("hostname label %q is longer than 63 characters", label)
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", fmt.Errorf("hostname label %q cannot start or end with a hyphen", label)
+		}
+		for _, char := range label {
+			if !((char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') || char == '-') {
+				return "", fmt.Errorf("hostname label %q can only contain letters, numbers, and hyphens", label)
+			}
+		}
+	}
+
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", fmt.Errorf("must be a hostname, not an IP address; use an A or AAAA record instead")
+	}
+
+	return hostname, nil
+}
+
+// FormatTXTValue turns TXT content into DNS presentation format: one or more
+// double-quoted strings of at most 255 bytes each, with quotes and
+// backslashes escaped. Content that is already quoted is parsed first, so
+// submitting a previously formatted value does not quote it twice.
+func FormatTXTValue(value string) (string, error) {
+	content, err := ParseTXTValue(value)
+	if err != nil {
+		return "", err
+	}
+
+	if content == "" {
+		return "", fmt.Errorf("TXT content cannot be empty")
+	}
+	if len(content) > maxTXTLength {
+		return "", fmt.Errorf("TXT content must be at most %d bytes", maxTXTLength)
+	}
+	if !utf8.ValidString(content) {
+		return "", fmt.Errorf("TXT content must be valid UTF-8")
+	}
+	for _, char := range content {
+		if unicode.IsControl(char) {
+			return "", fmt.Errorf("TXT content cannot contain control characters")
+		}
+	}
+
+	var chunks []string
+	for len(content) > 0 {
+		end := len(content)
+		if end > txtChunkLength {
+			end = txtChunkLength
+			for end > 0 && !utf8.RuneStart(content[end]) {
+				end--
+			}
+		}
+
+		chunk := strings.ReplaceAll(content[:end], `\`, `\\`)
+		chunk = strings.ReplaceAll(chunk, `"`, `\"`)
+		chunks = append(chunks, `"`+chunk+`"`)
+		content = content[end:]
+	}
+
+	return strings.Join(chunks, " "), nil
+}
+
+// ParseTXTValue returns the raw content of a TXT value. Values starting with a
+// double quote are read as a sequence of quoted strings and concatenated, with
+// \X and \DDD (a decimal byte value) escapes as in zone files; anything else
+// is taken literally.
+func ParseTXTValue(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, `"`) {
+		return value, nil
+	}
+
+	var content strings.Builder
+	for i := 0; i < len(value); {
+		switch value[i] {
+		case ' ', '\t':
+			i++
+			continue
+		case '"':
+		default:
+			return "", fmt.Errorf("TXT content has text outside of quotes")
+		}
+
+		i++
+		closed := false
+		for i < len(value) {
+			char := value[i]
+			if char == '\\' && i+3 < len(value) && isDigits(value[i+1:i+4]) {
+				code, _ := strconv.Atoi(value[i+1 : i+4])
+				if code > 255 {
+					return "", fmt.Errorf("TXT content has an invalid escape \\%s", value[i+1:i+4])
+				}
+				content.WriteByte(byte(code))
+				i += 4
+				continue
+			}
+			if char == '\\' && i+1 < len(value) {
+				content.WriteByte(value[i+1])
+				i += 2
+				continue
+			}
+			i++
+			if char == '"' {
+				closed = true
+				break
+			}
+			content.WriteByte(char)
+		}
+
+		if !closed {
+			return "", fmt.Errorf("TXT content has an unterminated quote")
+		}
+	}
+
+	return content.String(), nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
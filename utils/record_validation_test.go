@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseTXTValue(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: `v=spf1 -all`, want: `v=spf1 -all`},
+		{value: `"one" "two"`, want: `onetwo`},
+		{value: `"say \"hi\" \\o/"`, want: `say "hi" \o/`},
+		{value: `"a\032b"`, want: `a b`},
+		{value: `"caf\195\169"`, want: `café`},
+		{value: `"\065\066C"`, want: `ABC`},
+		{value: `"\12"`, want: `12`},
+		{value: `"\256"`, wantErr: true},
+		{value: `"\999"`, wantErr: true},
+		{value: `"unterminated`, wantErr: true},
+		{value: `"a" b`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTXTValue(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTXTValue(%s) = %q, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTXTValue(%s) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatTXTValue(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+	quote := func(chunks ...string) string { return `"` + strings.Join(chunks, `" "`) + `"` }
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "short", value: `v=spf1 -all`, want: `"v=spf1 -all"`},
+		{name: "escaped", value: `say "hi" \o/`, want: `"say \"hi\" \\o/"`},
+		{name: "quoted", value: `"one" "two"`, want: `"onetwo"`},
+		{name: "255 bytes", value: a(255), want: quote(a(255))},
+		{name: "256 bytes", value: a(256), want: quote(a(255), a(1))},
+		{name: "2048 bytes", value: a(2048), want: quote(a(255), a(255), a(255), a(255), a(255), a(255), a(255), a(255), a(8))},
+		{name: "2049 bytes", value: a(2049), wantErr: true},
+		{name: "rune across the edge", value: a(254) + "é" + a(3), want: quote(a(254), "é"+a(3))},
+		{name: "rune at the edge", value: a(253) + "é" + a(3), want: quote(a(253)+"é", a(3))},
+		{name: "4-byte rune across the edge", value: a(253) + "😀", want: quote(a(253), "😀")},
+		{name: "empty", value: `""`, wantErr: true},
+		{name: "control character", value: "a\tb", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := FormatTXTValue(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: FormatTXTValue = %q, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: FormatTXTValue = %q, %v, want %q", tt.name, got, err, tt.want)
+			continue
+		}
+
+		content, err := ParseTXTValue(got)
+		if want, _ := ParseTXTValue(tt.value); err != nil || content != want {
+			t.Errorf("%s: ParseTXTValue(FormatTXTValue) = %q, %v, want %q", tt.name, content, err, want)
+		}
+	}
+}
+
+func TestValidateRecordInput(t *testing.T) {
+	tests := []struct {
+		input     RecordInput
+		wantValue string
+		wantTTL   int
+		wantField string
+	}{
+		{input: RecordInput{Type: "A", Value: " 203.0.113.10 "}, wantValue: "203.0.113.10", wantTTL: 1},
+		{input: RecordInput{Type: "A", Value: "::ffff:203.0.113.10"}, wantField: "record_value"},
+		{input: RecordInput{Type: "A", Value: "203.0.113.010"}, wantField: "record_value"},
+		{input: RecordInput{Type: "A", Value: "2001:db8::10"}, wantField: "record_value"},
+		{input: RecordInput{Type: "AAAA", Value: "2001:DB8:0:0:0:0:0:10", TTL: 300}, wantValue: "2001:db8::10", wantTTL: 300},
+		{input: RecordInput{Type: "AAAA", Value: "::ffff:203.0.113.10"}, wantField: "record_value"},
+		{input: RecordInput{Type: "AAAA", Value: "fe80::1%eth0"}, wantField: "record_value"},
+		{input: RecordInput{Type: "AAAA", Value: "203.0.113.10"}, wantField: "record_value"},
+		{input: RecordInput{Type: "CNAME", Value: "WWW.Example.COM."}, wantValue: "www.example.com", wantTTL: 1},
+		{input: RecordInput{Type: "CNAME", Value: "203.0.113.10"}, wantField: "record_value"},
+		{input: RecordInput{Type: "CNAME", Value: "localhost"}, wantField: "record_value"},
+		{input: RecordInput{Type: "TXT", Value: `v=spf1 -all`, TTL: 1}, wantValue: `"v=spf1 -all"`, wantTTL: 1},
+		{input: RecordInput{Type: "TXT", Value: `"v=spf1" " -all"`}, wantValue: `"v=spf1 -all"`, wantTTL: 1},
+		{input: RecordInput{Type: "MX", Value: "mail.example.com"}, wantField: "record_type"},
+		{input: RecordInput{Type: "A", Value: "203.0.113.10", TTL: 30}, wantField: "ttl"},
+		{input: RecordInput{Type: "A", Value: "203.0.113.10", TTL: 86401}, wantField: "ttl"},
+	}
+
+	for _, tt := range tests {
+		input := tt.input
+		err := ValidateRecordInput(&input)
+		if tt.wantField != "" {
+			var verr *ValidationError
+			if !errors.As(err, &verr) || len(verr.Fields) != 1 || verr.Fields[0].Field != tt.wantField {
+				t.Errorf("ValidateRecordInput(%+v) = %v, want an error for %s", tt.input, err, tt.wantField)
+			}
+			continue
+		}
+		if err != nil || input.Value != tt.wantValue || input.TTL != tt.wantTTL {
+			t.Errorf("ValidateRecordInput(%+v) = %q, %d, %v, want %q, %d", tt.input, input.Value, input.TTL, err, tt.wantValue, tt.wantTTL)
+		}
+	}
+}
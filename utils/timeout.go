@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// queryCanceled is the SQLSTATE PostgreSQL reports when statement_timeout
+// cancels a query.
+const queryCanceled = "57014"
+
+// IsTimeout reports whether err was caused by a deadline: the request
+// context's, the database's statement timeout or an upstream client timeout.
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == queryCanceled {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,103 @@
+package webhooks
+
+import (
+	"btwarch/config"
+	"btwarch/repositories"
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+const deliveryBatchSize = 50
+
+// DeliveryJob sends due webhook deliveries and schedules retries with
+// exponential backoff until the attempt limit is reached.
+type DeliveryJob struct {
+	config      *config.Config
+	webhookRepo *repositories.WebhookRepository
+	sender      *Sender
+}
+
+func NewDeliveryJob(config *config.Config, webhookRepo *repositories.WebhookRepository, sender *Sender) *DeliveryJob {
+	return &DeliveryJob{
+		config:      config,
+		webhookRepo: webhookRepo,
+		sender:      sender,
+	}
+}
+
+func (j *DeliveryJob) Name() string {
+	return "webhook-delivery"
+}
+
+func (j *DeliveryJob) Run(ctx context.Context) error {
+	// Lease the batch for longer than it can take to send every delivery in it.
+	lease := time.Duration(deliveryBatchSize+1) * j.config.WebhookTimeout
+
+	pending, err := j.webhookRepo.ClaimDueDeliveries(ctx, deliveryBatchSize, lease)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pending {
+		j.deliver(ctx, p)
+	}
+
+	return nil
+}
+
+func (j *DeliveryJob) deliver(ctx context.Context, p *repositories.PendingDelivery) {
+	delivery := p.Delivery
+
+	if !p.WebhookActive {
+		if err := j.webhookRepo.MarkDeliveryFailed(ctx, delivery.ID, nil, "webhook is disabled", nil); err != nil {
+			slog.ErrorContext(ctx, "Error updating webhook delivery", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	status, err := j.sender.Send(ctx, p.URL, p.Secret, delivery.ID.String(), delivery.Event, delivery.Payload)
+	if err == nil {
+		if err := j.webhookRepo.MarkDeliverySucceeded(ctx, delivery.ID, status); err != nil {
+			slog.ErrorContext(ctx, "Error updating webhook delivery", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	var responseStatus *int
+	if status != 0 {
+		responseStatus = &status
+	}
+
+	attempts := delivery.Attempts + 1
+	var nextAttemptAt *time.Time
+	if attempts < j.config.WebhookMaxAttempts {
+		next := time.Now().Add(j.backoff(attempts))
+		nextAttemptAt = &next
+	}
+
+	message := err.Error()
+	if nextAttemptAt == nil {
+		message = fmt.Sprintf("%s (giving up after %d attempts)", message, attempts)
+	}
+
+	if err := j.webhookRepo.MarkDeliveryFailed(ctx, delivery.ID, responseStatus, message, nextAttemptAt); err != nil {
+		slog.ErrorContext(ctx, "Error updating webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// backoff doubles the retry delay with every attempt, up to the configured
+// maximum, and adds up to 10% jitter.
+func (j *DeliveryJob) backoff(attempts int) time.Duration {
+	delay := j.config.WebhookRetryBase
+	for i := 1; i < attempts && delay < j.config.WebhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > j.config.WebhookRetryMax {
+		delay = j.config.WebhookRetryMax
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/10+1))
+}
@@ -0,0 +1,43 @@
+package webhooks
+
+import (
+	"btwarch/events"
+	"btwarch/repositories"
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+// Dispatcher turns published events into queued deliveries for every
+// matching webhook of the event's user.
+type Dispatcher struct {
+	webhookRepo *repositories.WebhookRepository
+}
+
+func NewDispatcher(webhookRepo *repositories.WebhookRepository) *Dispatcher {
+	return &Dispatcher{webhookRepo: webhookRepo}
+}
+
+func (d *Dispatcher) Enqueue(event events.Event) {
+	ctx := context.Background()
+	webhooks, err := d.webhookRepo.GetActiveWebhooksForEvent(ctx, event.UserID, event.Type)
+	if err != nil {
+		slog.Error("Error getting webhooks", "event", event.Type, "error", err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Error encoding event", "event", event.Type, "error", err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if _, err := d.webhookRepo.CreateDelivery(ctx, webhook.ID, event.ID, event.Type, payload); err != nil {
+			slog.Error("Error queueing webhook delivery", "event", event.Type, "webhook_id", webhook.ID, "error", err)
+		}
+	}
+}
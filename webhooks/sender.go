@@ -0,0 +1,98 @@
+package webhooks
+
+import (
+	"btwarch/policy"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Sender posts signed webhook payloads.
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender returns a sender with the given request timeout. Unless
+// allowPrivateTargets is set, connections to loopback, private, link-local
+// and other non-public addresses are refused at dial time, after DNS
+// resolution.
+func NewSender(timeout time.Duration, allowPrivateTargets bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateTargets {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return CheckTargetAddress(host)
+		}
+	}
+
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// CheckTargetAddress rejects IP addresses that webhooks must not reach.
+func CheckTargetAddress(ip string) error {
+	if violation := policy.CheckPublicAddress(ip); violation != nil {
+		return fmt.Errorf("webhook target %s is not allowed: %s", ip, violation.Reason)
+	}
+	return nil
+}
+
+// Sign returns the signature header value for a payload: the Unix timestamp
+// and an HMAC-SHA256 over "<timestamp>.<payload>" keyed with the secret.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Send posts the payload and returns the response status. Any status outside
+// 2xx is returned together with an error.
+func (s *Sender) Send(ctx context.Context, url, secret, deliveryID, event string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("error building request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "btwarch-webhooks/1.0")
+	req.Header.Set("X-Btwarch-Event", event)
+	req.Header.Set("X-Btwarch-Delivery", deliveryID)
+	req.Header.Set("X-Btwarch-Signature", Sign(secret, time.Now().Unix(), payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}